@@ -0,0 +1,160 @@
+package game_controllers
+
+import (
+	"math/rand/v2"
+
+	"github.com/ungerik/go3d/vec2"
+	u "server/utils"
+)
+
+const (
+	CHARGE_ATTACK = "charge"
+	SLAM_ATTACK   = "slam"
+	SUMMON_ATTACK = "summon"
+)
+
+type BossStage int
+
+const (
+	BOSS_IDLE BossStage = iota
+	BOSS_TELEGRAPH
+	BOSS_ATTACKING
+)
+
+// BossEvent is emitted when boss starts to telegraph an attack or executes it
+type BossEvent struct {
+	Stage  BossStage
+	Attack string
+	Data   u.BossAttackData
+	Target Coordinate
+}
+
+// BossController drives boss between phases and attacks. Boss chases players using the flow field
+// while idle, stops while telegraphing an attack and then executes it.
+type BossController struct {
+	enemy  *Enemy
+	data   u.BossData
+	maxHp  float64
+	phase  int
+	stage  BossStage
+	attack string
+	target Coordinate
+	timer  float64
+	random *rand.Rand
+}
+
+func NewBossController(enemy *Enemy, maxHp float64, data u.BossData) *BossController {
+	b := &BossController{
+		enemy:  enemy,
+		data:   data,
+		maxHp:  maxHp,
+		stage:  BOSS_IDLE,
+		random: rand.New(rand.NewPCG(uint64(enemy.id), uint64(maxHp))),
+	}
+	b.UpdatePhase()
+	if b.phase < len(data.Phases) {
+		b.timer = data.Phases[b.phase].AttackCooldown
+	}
+	return b
+}
+
+// UpdatePhase moves boss to the phase matching its health and reports whether the phase changed,
+// phases are expected in config ordered by decreasing threshold
+func (b *BossController) UpdatePhase() bool {
+	hpLeft := b.enemy.hp / b.maxHp
+
+	phase := 0
+	for i, phaseData := range b.data.Phases {
+		if hpLeft <= phaseData.HPThreshold {
+			phase = i
+		}
+	}
+
+	changed := phase != b.phase
+	b.phase = phase
+	return changed
+}
+
+// Update advances boss by dt seconds and returns events of attacks that were telegraphed or executed
+func (b *BossController) Update(dt float64, players map[uint32]Coordinate) []BossEvent {
+	if b.phase >= len(b.data.Phases) {
+		return nil
+	}
+	phase := b.data.Phases[b.phase]
+	b.timer -= dt
+
+	switch b.stage {
+	case BOSS_IDLE:
+		if b.timer > 0 || len(phase.Attacks) == 0 || len(players) == 0 {
+			return nil
+		}
+
+		b.attack = phase.Attacks[b.random.IntN(len(phase.Attacks))]
+		b.target, _, _ = b.enemy.TargetPosition(players)
+		b.stage = BOSS_TELEGRAPH
+		b.timer = b.data.Attacks[b.attack].TelegraphTime
+		b.enemy.direction = vec2.T{0, 0}
+		return []BossEvent{b.event()}
+	case BOSS_TELEGRAPH:
+		b.enemy.direction = vec2.T{0, 0}
+		if b.timer > 0 {
+			return nil
+		}
+
+		attackData := b.data.Attacks[b.attack]
+		b.stage = BOSS_ATTACKING
+		b.timer = attackData.Duration
+		if attackData.Type == CHARGE_ATTACK {
+			b.enemy.direction = b.chargeDirection()
+		}
+		return []BossEvent{b.event()}
+	case BOSS_ATTACKING:
+		attackData := b.data.Attacks[b.attack]
+		if attackData.Type == CHARGE_ATTACK {
+			b.enemy.direction = b.chargeDirection()
+		} else {
+			b.enemy.direction = vec2.T{0, 0}
+		}
+		if b.timer > 0 {
+			return nil
+		}
+
+		b.stage = BOSS_IDLE
+		b.timer = phase.AttackCooldown
+	}
+	return nil
+}
+
+func (b *BossController) event() BossEvent {
+	return BossEvent{
+		Stage:  b.stage,
+		Attack: b.attack,
+		Data:   b.data.Attacks[b.attack],
+		Target: b.target,
+	}
+}
+
+// chargeDirection points at the place where the target was when the charge got telegraphed,
+// like flow field directions it has y axis pointing up. Speed of the charge is sent in boss event.
+func (b *BossController) chargeDirection() vec2.T {
+	direction := vec2.T{
+		float32(b.target.X - b.enemy.position.X),
+		float32(b.enemy.position.Y - b.target.Y),
+	}
+	if direction == (vec2.T{0, 0}) {
+		return direction
+	}
+	return *direction.Normalize()
+}
+
+func (b *BossController) GetPhase() int {
+	return b.phase
+}
+
+func (b *BossController) GetMaxHp() float64 {
+	return b.maxHp
+}
+
+func (b *BossController) GetEnemy() *Enemy {
+	return b.enemy
+}
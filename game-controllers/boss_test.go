@@ -0,0 +1,144 @@
+package game_controllers
+
+import (
+	"testing"
+
+	"github.com/ungerik/go3d/vec2"
+	u "server/utils"
+)
+
+// testBossData has three phases, each using a single attack, so tests know which attack comes next
+func testBossData() u.BossData {
+	return u.BossData{
+		Phases: []u.BossPhaseData{
+			{HPThreshold: 1.0, Attacks: []string{"charge"}, AttackCooldown: 2},
+			{HPThreshold: 0.6, Attacks: []string{"slam"}, AttackCooldown: 1.5},
+			{HPThreshold: 0.3, Attacks: []string{"summon"}, AttackCooldown: 1},
+		},
+		Attacks: map[string]u.BossAttackData{
+			"charge": {Type: CHARGE_ATTACK, TelegraphTime: 1, Duration: 0.5, Speed: 3},
+			"slam":   {Type: SLAM_ATTACK, TelegraphTime: 1, Duration: 0.5, Radius: 2, Damage: 10},
+			"summon": {Type: SUMMON_ATTACK, TelegraphTime: 1, Duration: 0.5, SummonName: "minion", SummonCount: 2},
+		},
+	}
+}
+
+func newTestBoss(x, y int, hp float64) *BossController {
+	enemy := NewTestEnemy(1, x, y)
+	enemy.hp = hp
+	return NewBossController(enemy, 100, testBossData())
+}
+
+func TestBossPhaseFollowsHealth(t *testing.T) {
+	tests := []struct {
+		hp    float64
+		phase int
+	}{
+		{100, 0},
+		{61, 0},
+		{60, 1},
+		{31, 1},
+		{30, 2},
+		{1, 2},
+	}
+
+	for _, test := range tests {
+		boss := newTestBoss(5, 5, 100)
+		boss.enemy.hp = test.hp
+		changed := boss.UpdatePhase()
+
+		if boss.GetPhase() != test.phase {
+			t.Errorf("boss with %v hp is in phase %d, expected %d", test.hp, boss.GetPhase(), test.phase)
+		}
+		if changed != (test.phase != 0) {
+			t.Errorf("boss with %v hp reported phase change %v", test.hp, changed)
+		}
+	}
+}
+
+func TestBossAttackCycle(t *testing.T) {
+	players := map[uint32]Coordinate{7: {X: 9, Y: 5}}
+	boss := newTestBoss(5, 5, 100)
+	boss.enemy.direction = vec2.T{1, 0}
+
+	steps := []struct {
+		name   string
+		dt     float64
+		events []BossStage
+		stage  BossStage
+	}{
+		{"waits for cooldown", 1.5, nil, BOSS_IDLE},
+		{"telegraphs after cooldown", 0.5, []BossStage{BOSS_TELEGRAPH}, BOSS_TELEGRAPH},
+		{"holds telegraph", 0.5, nil, BOSS_TELEGRAPH},
+		{"attacks after telegraph", 0.5, []BossStage{BOSS_ATTACKING}, BOSS_ATTACKING},
+		{"keeps attacking", 0.25, nil, BOSS_ATTACKING},
+		{"cools down after attack", 0.25, nil, BOSS_IDLE},
+		{"waits for the next cooldown", 1.5, nil, BOSS_IDLE},
+		{"telegraphs again", 0.5, []BossStage{BOSS_TELEGRAPH}, BOSS_TELEGRAPH},
+	}
+
+	for _, step := range steps {
+		events := boss.Update(step.dt, players)
+		if len(events) != len(step.events) {
+			t.Fatalf("%s: got events %v, expected stages %v", step.name, events, step.events)
+		}
+		for i, event := range events {
+			if event.Stage != step.events[i] || event.Attack != "charge" || event.Target != players[7] {
+				t.Errorf("%s: got event %+v", step.name, event)
+			}
+		}
+		if boss.stage != step.stage {
+			t.Fatalf("%s: boss is in stage %d, expected %d", step.name, boss.stage, step.stage)
+		}
+		if boss.stage == BOSS_TELEGRAPH && boss.enemy.direction != (vec2.T{0, 0}) {
+			t.Errorf("%s: boss moves with direction %v while telegraphing", step.name, boss.enemy.direction)
+		}
+	}
+}
+
+func TestBossWaitsForPlayers(t *testing.T) {
+	boss := newTestBoss(5, 5, 100)
+	if events := boss.Update(10, map[uint32]Coordinate{}); len(events) != 0 {
+		t.Errorf("boss without players got events %v", events)
+	}
+	if boss.stage != BOSS_IDLE {
+		t.Errorf("boss without players is in stage %d", boss.stage)
+	}
+}
+
+func TestBossChargeDirection(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    Coordinate
+		direction vec2.T
+	}{
+		{"right", Coordinate{X: 9, Y: 5}, vec2.T{1, 0}},
+		{"left", Coordinate{X: 1, Y: 5}, vec2.T{-1, 0}},
+		// tile rows grow downwards, directions have y axis pointing up
+		{"up", Coordinate{X: 5, Y: 1}, vec2.T{0, 1}},
+		{"down", Coordinate{X: 5, Y: 9}, vec2.T{0, -1}},
+		{"diagonal", Coordinate{X: 8, Y: 2}, vec2.T{0.70710677, 0.70710677}},
+		{"on top of the target", Coordinate{X: 5, Y: 5}, vec2.T{0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			boss := newTestBoss(5, 5, 100)
+			players := map[uint32]Coordinate{7: test.target}
+			boss.Update(2, players)
+			events := boss.Update(1, players)
+
+			if len(events) != 1 || events[0].Stage != BOSS_ATTACKING || events[0].Data.Speed != 3 {
+				t.Fatalf("expected charge with speed in the event, got %v", events)
+			}
+			direction := boss.enemy.direction
+			difference := vec2.Sub(&direction, &test.direction)
+			if difference.Length() > 1e-5 {
+				t.Errorf("charge got direction %v, expected %v", direction, test.direction)
+			}
+			if direction.Length() > 1+1e-5 {
+				t.Errorf("charge direction %v is longer than 1", direction)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package game_controllers
+
+import "math"
+
+// DEFAULT_CLEARANCE is clearance of enemies of unknown size, walls used to be padded by this many tiles for everybody
+const DEFAULT_CLEARANCE = 2
+
+// pathLayer keeps flow fields of enemies that need the same clearance, it's created
+// the first time such enemy shows up in the room
+type pathLayer struct {
+	field *FlowField
+	// fields of players chased by enemies closer to somebody else
+	targetFields map[uint32]*targetField
+	// fields of tiles where enemies search for players they lost from sight
+	searchFields map[Coordinate]*targetField
+	updatedAt    uint64
+}
+
+// SetClearance sets how many free tiles enemy needs between the tile it stands on and walls
+func (e *Enemy) SetClearance(clearance int) {
+	e.clearance = max(0, clearance)
+}
+
+// ClearanceForRadius returns clearance of enemy whose collision box reaches radius tiles from its center,
+// half of the tile it stands on is already covered, so box fitting inside one tile needs no free tiles around it
+func ClearanceForRadius(radius float64) int {
+	return max(0, int(math.Ceil(radius-0.5)))
+}
+
+// isBlockedFor reports whether tile given in map coordinates is blocked in flow fields of enemies
+// with the clearance, it doesn't create the layer, so read lock of the algorithm is enough
+func (a *AIAlgorithm) isBlockedFor(tile Coordinate, clearance int) bool {
+	grid := a.toGrid(tile)
+	if grid.X < 0 || grid.Y < 0 || grid.X >= a.width || grid.Y >= a.height || len(a.clearance) == 0 {
+		return false
+	}
+	return a.clearance[grid.Y*a.width+grid.X] < clearance
+}
+
+// layer returns flow fields of enemies with given clearance, tiles closer to walls are blocked in them
+func (a *AIAlgorithm) layer(clearance int) *pathLayer {
+	if layer, ok := a.layers[clearance]; ok {
+		return layer
+	}
+
+	layer := &pathLayer{
+		field:        NewFlowField(a.width, a.height),
+		targetFields: make(map[uint32]*targetField),
+		searchFields: make(map[Coordinate]*targetField),
+	}
+	for i, free := range a.clearance {
+		if free < clearance {
+			layer.field.SetBlocked(Coordinate{X: i % a.width, Y: i / a.width})
+		}
+	}
+	a.layers[clearance] = layer
+	return layer
+}
+
+// updateLayers repairs distances to players in layers of enemies present in the room,
+// layers nobody uses right now are repaired when their enemies come back
+func (a *AIAlgorithm) updateLayers() {
+	for _, enemy := range a.enemies {
+		layer := a.layer(enemy.clearance)
+		if layer.updatedAt == a.tick {
+			continue
+		}
+
+		layer.updatedAt = a.tick
+		layer.field.SetBounds(a.searchMin, a.searchMax)
+		layer.field.Update(a.sources)
+	}
+}
@@ -0,0 +1,204 @@
+package game_controllers
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+type RoomType int
+
+const (
+	START_ROOM RoomType = iota
+	COMBAT_ROOM
+	TREASURE_ROOM
+	BOSS_ROOM
+)
+
+var roomTypeNames = [...]string{"Start", "Combat", "Treasure", "Boss"}
+
+func (t RoomType) String() string {
+	return roomTypeNames[t]
+}
+
+type Room struct {
+	Id       int
+	Position Coordinate
+	Depth    int
+	Type     RoomType
+	Doors    []int
+}
+
+// Dungeon is a graph of rooms placed on a grid, rooms sharing a door are neighbours on the grid
+type Dungeon struct {
+	rooms      map[int]*Room
+	positions  map[Coordinate]*Room
+	startingId int
+}
+
+type DungeonSettings struct {
+	MaxDepth           int
+	StartingRoomId     int
+	SideRoomChance     float64
+	TreasureRoomChance float64
+}
+
+var roomDirections = [...]Coordinate{{X: 0, Y: -1}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: -1, Y: 0}}
+
+// GenerateDungeon creates dungeon with main path going from the starting room to the boss room
+// MaxDepth doors away, side rooms are attached to the main path. MaxDepth below 1 is taken as 1, so there is
+// always a boss room. The same seed always gives the same dungeon.
+func GenerateDungeon(seed int64, settings DungeonSettings) *Dungeon {
+	random := rand.New(rand.NewPCG(uint64(seed), uint64(seed)>>32))
+	d := &Dungeon{
+		rooms:      make(map[int]*Room),
+		positions:  make(map[Coordinate]*Room),
+		startingId: settings.StartingRoomId,
+	}
+
+	start := d.addRoom(Coordinate{X: 0, Y: 0}, 0, START_ROOM, nil)
+	// dungeon without depth would have no boss room
+	mainPath := d.addMainPath(random, start, max(1, settings.MaxDepth), make(map[Coordinate]bool))
+
+	// boss room is a dead end, so side rooms hang only on the rooms before it
+	for _, room := range mainPath[:len(mainPath)-1] {
+		if random.Float64() >= settings.SideRoomChance {
+			continue
+		}
+
+		position, ok := d.randomFreeNeighbour(random, room.Position, nil)
+		if !ok {
+			continue
+		}
+
+		roomType := COMBAT_ROOM
+		if random.Float64() < settings.TreasureRoomChance {
+			roomType = TREASURE_ROOM
+		}
+		d.addRoom(position, room.Depth+1, roomType, room)
+	}
+
+	return d
+}
+
+// addMainPath leads path of rooms from the starting room to the boss room maxDepth doors away, deadEnds are
+// positions where the path got trapped by its own rooms. It returns rooms of the path in order.
+func (d *Dungeon) addMainPath(random *rand.Rand, start *Room, maxDepth int, deadEnds map[Coordinate]bool) []*Room {
+	mainPath := []*Room{start}
+	for depth := 1; depth <= maxDepth; {
+		last := mainPath[len(mainPath)-1]
+		position, ok := d.randomFreeNeighbour(random, last.Position, deadEnds)
+		if !ok && last == start {
+			// every way was tried, only the starting room is left, so a straight path always fits
+			return d.addStraightPath(start, maxDepth)
+		}
+		if !ok {
+			// path goes back and tries another way, so it always reaches the boss room
+			deadEnds[last.Position] = true
+			d.removeLastRoom(mainPath[len(mainPath)-2])
+			mainPath = mainPath[:len(mainPath)-1]
+			depth--
+			continue
+		}
+
+		roomType := COMBAT_ROOM
+		if depth == maxDepth {
+			roomType = BOSS_ROOM
+		}
+		mainPath = append(mainPath, d.addRoom(position, depth, roomType, last))
+		depth++
+	}
+	return mainPath
+}
+
+// addStraightPath leads main path upwards from the starting room, it's used only when no other path was found
+func (d *Dungeon) addStraightPath(start *Room, maxDepth int) []*Room {
+	mainPath := []*Room{start}
+	for depth := 1; depth <= maxDepth; depth++ {
+		last := mainPath[len(mainPath)-1]
+		roomType := COMBAT_ROOM
+		if depth == maxDepth {
+			roomType = BOSS_ROOM
+		}
+		position := Coordinate{X: start.Position.X, Y: start.Position.Y - depth}
+		mainPath = append(mainPath, d.addRoom(position, depth, roomType, last))
+	}
+	return mainPath
+}
+
+func (d *Dungeon) addRoom(position Coordinate, depth int, roomType RoomType, previous *Room) *Room {
+	room := &Room{
+		Id:       d.startingId + len(d.rooms),
+		Position: position,
+		Depth:    depth,
+		Type:     roomType,
+		Doors:    make([]int, 0, len(roomDirections)),
+	}
+
+	if previous != nil {
+		room.Doors = append(room.Doors, previous.Id)
+		previous.Doors = append(previous.Doors, room.Id)
+	}
+
+	d.rooms[room.Id] = room
+	d.positions[position] = room
+	return room
+}
+
+// removeLastRoom removes the room added as the last one together with its door to the previous room
+func (d *Dungeon) removeLastRoom(previous *Room) {
+	room := d.rooms[d.startingId+len(d.rooms)-1]
+	delete(d.rooms, room.Id)
+	delete(d.positions, room.Position)
+	previous.Doors = slices.DeleteFunc(previous.Doors, func(door int) bool {
+		return door == room.Id
+	})
+}
+
+// randomFreeNeighbour picks position next to the given one that isn't taken by any room nor excluded
+func (d *Dungeon) randomFreeNeighbour(random *rand.Rand, position Coordinate, excluded map[Coordinate]bool) (Coordinate, bool) {
+	free := make([]Coordinate, 0, len(roomDirections))
+	for _, direction := range roomDirections {
+		neighbour := Coordinate{X: position.X + direction.X, Y: position.Y + direction.Y}
+		if _, taken := d.positions[neighbour]; !taken && !excluded[neighbour] {
+			free = append(free, neighbour)
+		}
+	}
+
+	if len(free) == 0 {
+		return Coordinate{}, false
+	}
+	return free[random.IntN(len(free))], true
+}
+
+func (d *Dungeon) GetStartingRoom() *Room {
+	return d.rooms[d.startingId]
+}
+
+func (d *Dungeon) GetRoom(id int) (*Room, bool) {
+	room, ok := d.rooms[id]
+	return room, ok
+}
+
+func (d *Dungeon) GetRoomAt(position Coordinate) (*Room, bool) {
+	room, ok := d.positions[position]
+	return room, ok
+}
+
+// GetRooms returns rooms ordered by their ids
+func (d *Dungeon) GetRooms() []*Room {
+	rooms := make([]*Room, 0, len(d.rooms))
+	for id := d.startingId; id < d.startingId+len(d.rooms); id++ {
+		rooms = append(rooms, d.rooms[id])
+	}
+	return rooms
+}
+
+// CanMove reports whether there is a door between two rooms
+func (d *Dungeon) CanMove(from, to *Room) bool {
+	for _, door := range from.Doors {
+		if door == to.Id {
+			return true
+		}
+	}
+	return false
+}
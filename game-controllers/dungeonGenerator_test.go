@@ -0,0 +1,127 @@
+package game_controllers
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+var testDungeonSettings = DungeonSettings{
+	MaxDepth:           6,
+	StartingRoomId:     1,
+	SideRoomChance:     0.5,
+	TreasureRoomChance: 0.3,
+}
+
+// bossRooms returns rooms of the dungeon of BOSS_ROOM type
+func bossRooms(d *Dungeon) []*Room {
+	bosses := make([]*Room, 0, 1)
+	for _, room := range d.GetRooms() {
+		if room.Type == BOSS_ROOM {
+			bosses = append(bosses, room)
+		}
+	}
+	return bosses
+}
+
+func TestGenerateDungeonIsDeterministic(t *testing.T) {
+	const seed = 1234
+
+	first := GenerateDungeon(seed, testDungeonSettings)
+	second := GenerateDungeon(seed, testDungeonSettings)
+	if !reflect.DeepEqual(first.GetRooms(), second.GetRooms()) {
+		t.Fatalf("dungeons generated from seed %d differ", seed)
+	}
+
+	bosses := bossRooms(first)
+	if len(bosses) != 1 {
+		t.Fatalf("expected exactly one boss room, got %d", len(bosses))
+	}
+	if bosses[0].Depth != testDungeonSettings.MaxDepth {
+		t.Errorf("boss room should be %d doors away from the start, got depth %d", testDungeonSettings.MaxDepth, bosses[0].Depth)
+	}
+}
+
+func TestGenerateDungeonAlwaysReachesBossRoom(t *testing.T) {
+	settings := testDungeonSettings
+	// long paths often trap themselves before reaching the boss room
+	settings.MaxDepth = 40
+
+	for seed := int64(0); seed < 200; seed++ {
+		d := GenerateDungeon(seed, settings)
+		bosses := bossRooms(d)
+		if len(bosses) != 1 {
+			t.Fatalf("seed %d: expected exactly one boss room, got %d", seed, len(bosses))
+		}
+		if bosses[0].Depth != settings.MaxDepth {
+			t.Errorf("seed %d: boss room has depth %d instead of %d", seed, bosses[0].Depth, settings.MaxDepth)
+		}
+
+		for _, room := range d.GetRooms() {
+			for _, door := range room.Doors {
+				other, ok := d.GetRoom(door)
+				if !ok || !d.CanMove(other, room) {
+					t.Fatalf("seed %d: door from room %d to room %d goes one way only", seed, room.Id, door)
+				}
+				if abs(other.Position.X-room.Position.X)+abs(other.Position.Y-room.Position.Y) != 1 {
+					t.Fatalf("seed %d: rooms %d and %d share a door but aren't next to each other", seed, room.Id, door)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateDungeonEdgeSettings(t *testing.T) {
+	tests := []struct {
+		maxDepth  int
+		bossDepth int
+	}{
+		{-3, 1},
+		{0, 1},
+		{1, 1},
+		{2, 2},
+	}
+
+	for _, test := range tests {
+		settings := testDungeonSettings
+		settings.MaxDepth = test.maxDepth
+		settings.SideRoomChance = 1
+
+		for seed := int64(0); seed < 20; seed++ {
+			d := GenerateDungeon(seed, settings)
+			bosses := bossRooms(d)
+			if len(bosses) != 1 {
+				t.Fatalf("max depth %d, seed %d: expected exactly one boss room, got %d", test.maxDepth, seed, len(bosses))
+			}
+			if bosses[0].Depth != test.bossDepth || len(bosses[0].Doors) != 1 {
+				t.Errorf("max depth %d, seed %d: boss room has depth %d and %d doors", test.maxDepth, seed, bosses[0].Depth, len(bosses[0].Doors))
+			}
+		}
+	}
+}
+
+func TestMainPathFallsBackToStraightPath(t *testing.T) {
+	d := &Dungeon{
+		rooms:     make(map[int]*Room),
+		positions: make(map[Coordinate]*Room),
+	}
+	start := d.addRoom(Coordinate{X: 0, Y: 0}, 0, START_ROOM, nil)
+	// every way out of the starting room was already tried
+	deadEnds := make(map[Coordinate]bool)
+	for _, direction := range roomDirections {
+		deadEnds[direction] = true
+	}
+
+	mainPath := d.addMainPath(rand.New(rand.NewPCG(1, 1)), start, 5, deadEnds)
+	if len(mainPath) != 6 || len(d.rooms) != 6 {
+		t.Fatalf("main path has %d rooms and dungeon %d, expected 6", len(mainPath), len(d.rooms))
+	}
+	for depth, room := range mainPath[1:] {
+		if room.Depth != depth+1 || !d.CanMove(mainPath[depth], room) {
+			t.Errorf("room %d of main path has depth %d or isn't connected to the previous one", depth+1, room.Depth)
+		}
+	}
+	if mainPath[5].Type != BOSS_ROOM {
+		t.Errorf("main path ends with %v room instead of boss room", mainPath[5].Type)
+	}
+}
@@ -1,6 +1,9 @@
 package game_controllers
 
 import (
+	"math"
+	"time"
+
 	"github.com/ungerik/go3d/vec2"
 	u "server/utils"
 )
@@ -14,6 +17,21 @@ type Enemy struct {
 	textureData       u.TextureData
 	collisionData     u.CollisionData
 	previousDirection vec2.T
+	// knockback velocity in tiles per second and distance travelled by it that doesn't make a whole tile yet
+	knockback, knockbackOffset vec2.T
+	targeting                  string
+	threat                     map[uint32]float64
+	// players that hit enemy last and that enemy chases
+	lastAttacker, target uint32
+	// distance in tiles enemy keeps from others and how strongly it's kept
+	separationRadius, separationWeight float64
+	// free tiles enemy needs around itself to fit between walls
+	clearance int
+	// players enemy saw, zero vision range means enemy knows where everybody is
+	visionRange float64
+	memory      time.Duration
+	sightings   map[uint32]sighting
+	noticed     map[uint32]bool
 }
 
 func NewEnemy(id uint32, x, y int, typ, name string, hp, damage float64, textureData u.TextureData, collisionData u.CollisionData) *Enemy {
@@ -28,13 +46,22 @@ func NewEnemy(id uint32, x, y int, typ, name string, hp, damage float64, texture
 		damage:            damage,
 		textureData:       textureData,
 		collisionData:     collisionData,
+		targeting:         TARGET_PROXIMITY,
+		threat:            make(map[uint32]float64),
+		lastAttacker:      NO_TARGET,
+		target:            NO_TARGET,
+		clearance:         DEFAULT_CLEARANCE,
 	}
 }
 
 func NewTestEnemy(id uint32, x, y int) *Enemy {
 	return &Enemy{
-		id:       id,
-		position: Coordinate{x, y},
+		id:           id,
+		position:     Coordinate{x, y},
+		targeting:    TARGET_PROXIMITY,
+		lastAttacker: NO_TARGET,
+		target:       NO_TARGET,
+		clearance:    DEFAULT_CLEARANCE,
 	}
 }
 
@@ -46,10 +73,32 @@ func (e *Enemy) SetPosition(newX, newY int) {
 	e.position = Coordinate{newX, newY}
 }
 
+// ClosestPlayer returns position of the nearest player and distance to it in tiles
+func (e *Enemy) ClosestPlayer(players map[uint32]Coordinate) (Coordinate, float64, bool) {
+	closest := Coordinate{}
+	closestDistance := math.MaxFloat64
+	for _, player := range players {
+		distance := e.distanceTo(player)
+		if distance < closestDistance {
+			closest = player
+			closestDistance = distance
+		}
+	}
+	return closest, closestDistance, len(players) > 0
+}
+
+func (e *Enemy) distanceTo(tile Coordinate) float64 {
+	return math.Hypot(float64(tile.X-e.position.X), float64(tile.Y-e.position.Y))
+}
+
 func (e *Enemy) GetId() uint32 {
 	return e.id
 }
 
+func (e *Enemy) SetDirection(direction vec2.T) {
+	e.direction = direction
+}
+
 func (e *Enemy) GetDirectionX() float32 {
 	return e.direction.Get(1, 0)
 }
@@ -70,6 +119,16 @@ func (e *Enemy) GetHp() float64 {
 	return e.hp
 }
 
+func (e *Enemy) SetHp(hp float64) {
+	e.hp = hp
+}
+
+// TakeDamage lowers health of enemy and reports whether it died
+func (e *Enemy) TakeDamage(damage float64) bool {
+	e.hp = max(0, e.hp-damage)
+	return e.hp == 0
+}
+
 func (e *Enemy) GetDamage() float64 {
 	return e.damage
 }
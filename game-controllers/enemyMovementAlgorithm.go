@@ -1,162 +1,95 @@
 package game_controllers
 
 import (
-	"errors"
-	"fmt"
-	"github.com/ungerik/go3d/vec2"
-	"log"
 	"math"
 	"sync"
-)
-
-var (
-	UP         int
-	DOWN       int
-	LEFT       int
-	RIGHT      int
-	UP_LEFT    int
-	UP_RIGHT   int
-	DOWN_LEFT  int
-	DOWN_RIGHT int
-	IDLE       int
-	COLLISION  int
-	MIN        int
+	"time"
 )
 
 type AIAlgorithm struct {
 	Mutex                                          sync.RWMutex
 	width, height, offsetWidth, offsetHeight       int
 	collisions                                     []Coordinate
+	obstacles                                      map[Coordinate]bool
 	players                                        map[uint32]Coordinate
 	enemies                                        map[uint32]*Enemy
-	graph                                          *[][]Cell
+	sources                                        map[uint32]Coordinate
 	minBorderX, minBorderY, maxBorderX, maxBorderY int
-	debug                                          bool
-}
-
-type Cell struct {
-	direction *vec2.T
-	value     int
+	// search is limited to borders extended by margin, negative margin searches the whole map
+	searchMargin int
+	bounded      bool
+	// number of enemies when search inside borders found no path for some of them
+	unboundedEnemies int
+	// free tiles between every tile and the nearest wall, -1 for walls
+	clearance []int
+	// flow fields of enemies that need the same clearance
+	layers map[int]*pathLayer
+	// distances are searched only between these tiles
+	searchMin, searchMax Coordinate
+	targetSource         map[uint32]Coordinate
+	known                map[uint32]Coordinate
+	tick                 uint64
+	clock                func() time.Time
 }
 
 type Coordinate struct {
 	X, Y int
 }
 
-func (c *Cell) GetDirection() vec2.T {
-	return *(c.direction)
-}
-
-func (c *Cell) GetCellValue() int {
-	return c.value
-}
-
 func NewAIAlgorithm() *AIAlgorithm {
-	return &AIAlgorithm{}
-}
-
-func (a *AIAlgorithm) initDirections() {
-	MIN = 0
-	UP = a.width + a.height + 1
-	DOWN = UP + 1
-	LEFT = DOWN + 1
-	RIGHT = LEFT + 1
-	UP_LEFT = RIGHT + 1
-	UP_RIGHT = UP_LEFT + 1
-	DOWN_LEFT = UP_RIGHT + 1
-	DOWN_RIGHT = DOWN_LEFT + 1
-	IDLE = DOWN_RIGHT + 1
-	COLLISION = IDLE + 1
+	return &AIAlgorithm{
+		searchMargin: -1,
+		sources:      make(map[uint32]Coordinate),
+		layers:       make(map[int]*pathLayer),
+		targetSource: make(map[uint32]Coordinate),
+		known:        make(map[uint32]Coordinate),
+		clock:        time.Now,
+	}
 }
 
+// InitGraph builds grid of the map from dimensions, offset and collisions set before
 func (a *AIAlgorithm) InitGraph() {
-	graph := make([][]Cell, a.height)
-	for i := range graph {
-		graph[i] = make([]Cell, a.width)
-	}
-
-	a.graph = &graph
-	log.Printf("Created graph, width: %d, height: %d\n", a.width, a.height)
-
-	a.expandCollisions()
-	a.addCollisions()
-	a.debug = false
+	a.SetGrid(NewGrid(a.width, a.height, a.offsetWidth, a.offsetHeight, a.collisions))
 }
 
+// CreateDistancesMap repairs distances to players that moved since the last call
+// and points enemies towards the players they chase
 func (a *AIAlgorithm) CreateDistancesMap() {
-	a.initDirections()
-	a.addPlayers()
-	a.addCollisions()
+	if a.clearance == nil {
+		return
+	}
+	a.tick++
 	a.findBorders()
+	a.updateSearchBounds()
 
-	err := a.bfs()
-	if err != nil {
-		fmt.Println(err)
+	clear(a.sources)
+	for id, player := range a.players {
+		a.sources[id] = a.toGrid(player)
 	}
-	a.fillDirections()
+	a.updateLayers()
 
-	// change flag to true to print graph
-	if a.debug {
-		a.debug = false
-		a.printGraphWithAxes()
+	if a.bounded && !a.enemiesReachable() {
+		// path around a wall may lead outside the borders, whole map is searched until enemies change
+		a.bounded = false
+		a.unboundedEnemies = len(a.enemies)
+		a.removeSearchBounds()
+		a.tick++
+		a.updateLayers()
 	}
+	a.updateEnemyDirections()
+	a.separateEnemies()
 }
 
+// ClearGraph forgets distances to players, the next CreateDistancesMap computes them from scratch
 func (a *AIAlgorithm) ClearGraph() {
-	for i := range *a.graph {
-		row := (*a.graph)[i]
-		for j := range row {
-			row[j].direction = nil
-			row[j].value = 0
-		}
-	}
-}
-
-func (a *AIAlgorithm) addPlayers() {
-	for _, player := range a.players {
-		x := player.X - a.offsetWidth
-		y := player.Y - a.offsetHeight
-		if x < a.width && x >= 0 && y < a.height && y >= 0 {
-			(*a.graph)[y][x] = Cell{&vec2.T{0, 0}, MIN}
-		}
+	for _, layer := range a.layers {
+		layer.field.Reset()
+		layer.updatedAt = 0
+		clear(layer.targetFields)
+		clear(layer.searchFields)
 	}
 }
 
-func (a *AIAlgorithm) addCollisions() {
-	for _, coll := range a.collisions {
-		x := coll.X - a.offsetWidth
-		y := coll.Y - a.offsetHeight
-		if x < a.width && x >= 0 && y < a.height && y >= 0 {
-			(*a.graph)[y][x] = Cell{&vec2.T{0, 0}, COLLISION}
-		}
-	}
-}
-
-func (a *AIAlgorithm) expandCollisions() {
-	padding := 2
-
-	expandedCollisions := make([]Coordinate, 0)
-
-	for _, coll := range a.collisions {
-		x := coll.X
-		y := coll.Y
-
-		for dy := -padding; dy <= padding; dy++ {
-			for dx := -padding; dx <= padding; dx++ {
-				newX := x + dx
-				newY := y + dy
-
-				expandedCollisions = append(expandedCollisions, Coordinate{
-					X: newX,
-					Y: newY,
-				})
-			}
-		}
-	}
-
-	a.collisions = expandedCollisions
-}
-
 func (a *AIAlgorithm) findBorders() {
 	minBorderX := math.MaxInt
 	maxBorderX := 0
@@ -184,157 +117,107 @@ func (a *AIAlgorithm) findBorders() {
 	a.minBorderY = max(0, min(minBorderY, a.height-1))
 }
 
-func (a *AIAlgorithm) bfs() error {
+// updateSearchBounds limits search to borders of players and enemies extended by margin. Bounds are kept
+// as long as everybody stays inside them, so flow fields can be repaired instead of computed again.
+func (a *AIAlgorithm) updateSearchBounds() {
+	if a.searchMargin < 0 || len(a.players) == 0 {
+		a.bounded = false
+		a.removeSearchBounds()
+		return
+	}
+	if !a.bounded && len(a.enemies) == a.unboundedEnemies {
+		return
+	}
 
-	queue := Queue{}
-	for _, player := range a.players {
-		playerWithOffset := Coordinate{
-			X: player.X - a.offsetWidth,
-			Y: player.Y - a.offsetHeight}
-		queue.put(playerWithOffset)
+	minBorder := Coordinate{X: a.minBorderX, Y: a.minBorderY}
+	maxBorder := Coordinate{X: a.maxBorderX, Y: a.maxBorderY}
+	if a.bounded && a.searchContains(minBorder) && a.searchContains(maxBorder) {
+		return
 	}
 
-	for {
-		if queue.isEmpty() {
-			break
-		}
+	a.bounded = true
+	a.unboundedEnemies = -1
+	a.searchMin = Coordinate{X: max(0, minBorder.X-a.searchMargin), Y: max(0, minBorder.Y-a.searchMargin)}
+	a.searchMax = Coordinate{X: min(a.width-1, maxBorder.X+a.searchMargin), Y: min(a.height-1, maxBorder.Y+a.searchMargin)}
+}
 
-		current, ok := queue.get()
-		if !ok {
-			return errors.New("queue is empty")
-		}
+func (a *AIAlgorithm) removeSearchBounds() {
+	a.searchMin = Coordinate{X: 0, Y: 0}
+	a.searchMax = Coordinate{X: a.width - 1, Y: a.height - 1}
+}
 
-		if current.X >= 0 && current.X < a.width && current.Y >= 0 && current.Y < a.height {
-			neighbors := a.getNeighbors(current)
-			for _, next := range neighbors {
-				found := (*a.graph)[next.Y][next.X]
-				if found.direction == nil {
-					queue.put(next)
-					distance := (*a.graph)[current.Y][current.X].value + 1
-					if distance < (*a.graph)[next.Y][next.X].value {
-						distance = (*a.graph)[next.Y][next.X].value
-					}
-					(*a.graph)[next.Y][next.X] = Cell{&vec2.T{0, 0}, distance}
-				}
-			}
-		}
-	}
-	return nil
+func (a *AIAlgorithm) searchContains(tile Coordinate) bool {
+	return tile.X >= a.searchMin.X && tile.X <= a.searchMax.X && tile.Y >= a.searchMin.Y && tile.Y <= a.searchMax.Y
 }
 
-func (a *AIAlgorithm) fillDirections() {
-	for i := 0; i < a.height; i++ {
-		for j := 0; j < a.width; j++ {
-			value := (*a.graph)[i][j].value
-			if value != MIN && value != COLLISION {
-				(*a.graph)[i][j].direction = a.parseToMove(Coordinate{X: j, Y: i})
+// enemiesReachable reports whether every enemy that isn't stuck in a wall has path to some player
+func (a *AIAlgorithm) enemiesReachable() bool {
+	for _, enemy := range a.enemies {
+		field := a.layer(enemy.clearance).field
+		tile := a.toGrid(enemy.position)
+		if !field.IsBlocked(tile) {
+			if _, ok := field.Distance(tile); !ok {
+				return false
 			}
 		}
 	}
+	return true
+}
 
-	for _, enemy := range a.enemies {
-		position := enemy.position
+// toGrid converts map coordinates to coordinates in the flow field
+func (a *AIAlgorithm) toGrid(tile Coordinate) Coordinate {
+	return Coordinate{X: tile.X - a.offsetWidth, Y: tile.Y - a.offsetHeight}
+}
 
-		y := position.Y - a.offsetHeight
-		x := position.X - a.offsetWidth
+// IsObstacle reports whether tile with given map coordinates is a wall
+func (a *AIAlgorithm) IsObstacle(tile Coordinate) bool {
+	return a.obstacles[tile]
+}
 
-		vector := (*a.graph)[y][x].direction
+// CrossesObstacle reports whether straight line between two tiles goes through a wall,
+// tiles on both ends of the line are not checked
+func (a *AIAlgorithm) CrossesObstacle(from, to Coordinate) bool {
+	dx := abs(to.X - from.X)
+	dy := -abs(to.Y - from.Y)
+	stepX := sign(to.X - from.X)
+	stepY := sign(to.Y - from.Y)
+	errorValue := dx + dy
 
-		if vector == nil {
-			continue
+	current := from
+	for current != to {
+		doubledError := 2 * errorValue
+		if doubledError >= dy {
+			errorValue += dy
+			current.X += stepX
+		}
+		if doubledError <= dx {
+			errorValue += dx
+			current.Y += stepY
 		}
 
-		// TODO kinda temporary solution it can be done better
-		enemy.direction = *vector
-
-		// TODO left for debug in bright future
-		//vecX := vector[0]
-		//vecY := vector[1]
-		//previousX := enemy.GetPreviousDirectionX()
-		//previousY := enemy.GetPreviousDirectionY()
-		//if previousX == 0 && previousY == 0 {
-		//	enemy.SetDirection(*vector)
-		//	enemy.SetPreviousDirection(*vector)
-		//	continue
-		//}
-
-		//passedTileX := int(enemy.posX)
-		//passedTileY := int(enemy.posY)
-		//log.Printf("Vector %f, %f, mapX: %d, mapY: %d\n", vecX, vecY, x, y)
-		//if passedTileX%16 < 1 && passedTileY%16 < 1 {
-		//	enemy.SetDirection(*vector)
-		//} else if passedTileX%16 < 1 {
-		//	if vecY != 0 {
-		//		enemy.SetDirection(vec2.T{0, vecY})
-		//	} else {
-		//		enemy.SetDirection(vec2.T{previousX, previousY})
-		//	}
-		//} else if passedTileY%16 < 4 {
-		//	if vecX != 0 {
-		//		enemy.SetDirection(vec2.T{vecX, 0})
-		//	} else {
-		//		enemy.SetDirection(vec2.T{previousX, previousY})
-		//	}
-		//}
-
-		//enemy.SetPreviousDirection(enemy.GetDirection())
+		if current != to && a.obstacles[current] {
+			return true
+		}
 	}
+	return false
 }
 
-func (a *AIAlgorithm) parseToMove(vertex Coordinate) *vec2.T {
-	neighbors := a.getNeighbors(vertex)
-	x := 0
-	y := 0
-
-	if (*a.graph)[neighbors[UP].Y][neighbors[UP].X].value == COLLISION && (*a.graph)[neighbors[DOWN].Y][neighbors[DOWN].X].value == COLLISION {
-		y = 0
-	} else if (*a.graph)[neighbors[UP].Y][neighbors[UP].X].value == COLLISION {
-		y = (*a.graph)[neighbors[DOWN].Y][neighbors[DOWN].X].value - (*a.graph)[vertex.Y][vertex.X].value - 1
-	} else if (*a.graph)[neighbors[DOWN].Y][neighbors[DOWN].X].value == COLLISION {
-		y = (*a.graph)[vertex.Y][vertex.X].value - (*a.graph)[neighbors[UP].Y][neighbors[UP].X].value + 1
-	} else {
-		y = (*a.graph)[neighbors[DOWN].Y][neighbors[DOWN].X].value - (*a.graph)[neighbors[UP].Y][neighbors[UP].X].value
+func abs(value int) int {
+	if value < 0 {
+		return -value
 	}
-
-	if (*a.graph)[neighbors[LEFT].Y][neighbors[LEFT].X].value == COLLISION && (*a.graph)[neighbors[RIGHT].Y][neighbors[RIGHT].X].value == COLLISION {
-		x = 0
-	} else if (*a.graph)[neighbors[LEFT].Y][neighbors[LEFT].X].value == COLLISION {
-		x = (*a.graph)[vertex.Y][vertex.X].value - (*a.graph)[neighbors[RIGHT].Y][neighbors[RIGHT].X].value + 1
-	} else if (*a.graph)[neighbors[RIGHT].Y][neighbors[RIGHT].X].value == COLLISION {
-		x = (*a.graph)[neighbors[LEFT].Y][neighbors[LEFT].X].value - (*a.graph)[vertex.Y][vertex.X].value - 1
-	} else {
-		x = (*a.graph)[neighbors[LEFT].Y][neighbors[LEFT].X].value - (*a.graph)[neighbors[RIGHT].Y][neighbors[RIGHT].X].value
-	}
-
-	move := vec2.T{float32(x), float32(y)}
-	return move.Normalize()
+	return value
 }
 
-func (a *AIAlgorithm) getNeighbors(vertex Coordinate) map[int]Coordinate {
-	neighbors := map[int]Coordinate{
-		UP:    {X: vertex.X, Y: max(0, vertex.Y-1)},
-		LEFT:  {X: max(0, vertex.X-1), Y: vertex.Y},
-		DOWN:  {X: vertex.X, Y: min(a.height-1, vertex.Y+1)},
-		RIGHT: {X: min(a.width-1, vertex.X+1), Y: vertex.Y},
+func sign(value int) int {
+	switch {
+	case value < 0:
+		return -1
+	case value > 0:
+		return 1
+	default:
+		return 0
 	}
-	return neighbors
-}
-
-func (a *AIAlgorithm) getNeighborsExtended(vertex Coordinate) map[int]Coordinate {
-	neighbors := make(map[int]Coordinate)
-
-	neighbors[UP] = Coordinate{X: vertex.X, Y: max(0, vertex.Y-1)}
-	neighbors[LEFT] = Coordinate{X: max(0, vertex.X-1), Y: vertex.Y}
-	neighbors[DOWN] = Coordinate{X: vertex.X, Y: min(a.height-1, vertex.Y+1)}
-	neighbors[RIGHT] = Coordinate{X: min(a.width-1, vertex.X+1), Y: vertex.Y}
-
-	// TODO also left for debug in brightest future
-	//neighbors[UP_LEFT] = Coordinate{X: max(0, vertex.X-1), Y: max(0, vertex.Y-1)}
-	//neighbors[UP_RIGHT] = Coordinate{X: min(a.width-1, vertex.X+1), Y: max(0, vertex.Y-1)}
-	//neighbors[DOWN_LEFT] = Coordinate{X: max(0, vertex.X-1), Y: min(a.height-1, vertex.Y+1)}
-	//neighbors[DOWN_RIGHT] = Coordinate{X: min(a.width-1, vertex.X+1), Y: min(a.height-1, vertex.Y+1)}
-
-	return neighbors
 }
 
 func (a *AIAlgorithm) SetWidth(width int) {
@@ -345,6 +228,12 @@ func (a *AIAlgorithm) SetHeight(height int) {
 	a.height = height
 }
 
+// SetSearchMargin sets how many tiles around players and enemies are searched for paths,
+// negative margin searches the whole map
+func (a *AIAlgorithm) SetSearchMargin(margin int) {
+	a.searchMargin = margin
+}
+
 func (a *AIAlgorithm) SetOffset(offsetWidth, offsetHeight int) {
 	a.offsetWidth = offsetWidth
 	a.offsetHeight = offsetHeight
@@ -361,73 +250,3 @@ func (a *AIAlgorithm) SetEnemies(enemies map[uint32]*Enemy) {
 func (a *AIAlgorithm) SetCollision(collisions []Coordinate) {
 	a.collisions = collisions
 }
-
-// printing functions used for debug purposes
-func (a *AIAlgorithm) printGraphWithAxes2() {
-	fmt.Print("    ")
-	for i := 0; i < len((*a.graph)[0]); i++ {
-		fmt.Printf("%2d |", i)
-	}
-	fmt.Print("\n")
-
-	for i, row := range *(a.graph) {
-		fmt.Printf("%2d ", i)
-		for _, el := range row {
-			if el.value != COLLISION {
-				fmt.Printf("%s|", getDirectionArrow(0, 0))
-			} else {
-				fmt.Printf(" C |")
-			}
-
-		}
-		fmt.Print("\n")
-	}
-}
-
-func (a *AIAlgorithm) printGraphWithAxes() {
-	fmt.Print("    ")
-	for i := 0; i < len((*a.graph)[0]); i++ {
-		fmt.Printf("%2d |", i)
-	}
-	fmt.Print("\n")
-
-	enemySpawned := false
-	for i, row := range *(a.graph) {
-		fmt.Printf("%2d  ", i)
-		for j, el := range row {
-			for _, enemy := range a.enemies {
-				position := enemy.position
-				if position.X-a.offsetWidth == j && position.Y-a.offsetHeight == i {
-					fmt.Printf(" ● |")
-					enemySpawned = true
-				}
-			}
-			if !enemySpawned {
-				if el.value != COLLISION && el.direction != nil {
-					fmt.Printf("%s|", getDirectionArrow(float64(el.direction.Get(1, 0)), float64(el.direction.Get(0, 1))))
-				} else if el.value == COLLISION {
-					fmt.Printf(" C |")
-					//fmt.Printf("%s||", getDirectionArrow(0.0, 0.0))
-				} else {
-					fmt.Printf("%s|", getDirectionArrow(0, 0))
-				}
-			}
-			enemySpawned = false
-		}
-		fmt.Print("\n")
-	}
-}
-
-func getDirectionArrow(x, y float64) string {
-	//if x == 0 && y == 0 {
-	//	return " ● "
-	//}
-	if x == 0 && y == 0 {
-		return "   "
-	}
-
-	angle := math.Atan2(y, x)
-	directions := []string{" → ", " ↗ ", " ↑ ", " ↖ ", " ← ", " ↙ ", " ↓ ", " ↘ "}
-	index := int(math.Round(angle/(math.Pi/4)+8)) % 8
-	return directions[index]
-}
@@ -0,0 +1,256 @@
+package game_controllers
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+var searchModes = []struct {
+	name   string
+	margin int
+}{
+	{"full", -1},
+	{"bounded", 8},
+}
+
+// benchmarkRoom builds algorithm for room surrounded by walls with a row of pillars in the middle,
+// players start in one corner and enemies wait in the opposite one
+func benchmarkRoom(b *testing.B, width, height, margin int) *AIAlgorithm {
+	b.Helper()
+
+	collisions := make([]Coordinate, 0)
+	for x := 0; x < width; x++ {
+		collisions = append(collisions, Coordinate{X: x, Y: 0}, Coordinate{X: x, Y: height - 1})
+	}
+	for y := 0; y < height; y++ {
+		collisions = append(collisions, Coordinate{X: 0, Y: y}, Coordinate{X: width - 1, Y: y})
+	}
+	for x := width / 4; x < width*3/4; x += 8 {
+		collisions = append(collisions, Coordinate{X: x, Y: height / 2})
+	}
+
+	a := NewAIAlgorithm()
+	a.SetWidth(width)
+	a.SetHeight(height)
+	a.SetOffset(0, 0)
+	a.SetCollision(collisions)
+	a.SetSearchMargin(margin)
+	a.InitGraph()
+
+	enemies := make(map[uint32]*Enemy)
+	for i := uint32(0); i < 10; i++ {
+		enemies[100+i] = NewTestEnemy(100+i, width/2+int(i), height/2+4)
+	}
+	a.SetEnemies(enemies)
+	return a
+}
+
+// reducesDistance reports whether step along direction leads to a tile closer to players,
+// directions have y axis pointing up, so positive y goes to the row above
+func reducesDistance(field *FlowField, tile Coordinate, direction vec2.T) bool {
+	distance, _ := field.Distance(tile)
+	steps := make([]Coordinate, 0, 2)
+	if dx := int(math.Round(float64(direction[0]))); dx != 0 {
+		steps = append(steps, Coordinate{X: tile.X + dx, Y: tile.Y})
+	}
+	if dy := int(math.Round(float64(direction[1]))); dy != 0 {
+		steps = append(steps, Coordinate{X: tile.X, Y: tile.Y - dy})
+	}
+
+	for _, next := range steps {
+		if nextDistance, ok := field.Distance(next); ok && nextDistance < distance {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateDistancesMapMovesEnemiesTowardsPlayers(t *testing.T) {
+	for _, name := range fixtureNames(t) {
+		for _, mode := range searchModes {
+			t.Run(name+"/"+mode.name, func(t *testing.T) {
+				fixture := loadFixture(t, name)
+				a := fixture.algorithm(mode.margin)
+				a.CreateDistancesMap()
+
+				for id, enemy := range a.enemies {
+					field := a.layer(enemy.clearance).field
+					tile := a.toGrid(enemy.position)
+					distance, reachable := field.Distance(tile)
+
+					switch {
+					case fixture.isUnreachable(id):
+						if reachable {
+							t.Errorf("enemy %d should be walled off, got distance %d", id, distance)
+						}
+					case !reachable:
+						t.Errorf("enemy %d has no path to any player", id)
+					case distance == 0:
+					case enemy.direction == vec2.T{0, 0}:
+						t.Errorf("enemy %d at distance %d got no direction", id, distance)
+					case !reducesDistance(field, tile, enemy.direction):
+						t.Errorf("direction %v of enemy %d at distance %d doesn't lead closer to players", enemy.direction, id, distance)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestFlowFieldDirectionsReduceDistance(t *testing.T) {
+	for _, name := range fixtureNames(t) {
+		t.Run(name, func(t *testing.T) {
+			fixture := loadFixture(t, name)
+			a := fixture.algorithm(-1)
+			a.CreateDistancesMap()
+
+			for clearance, layer := range a.layers {
+				for y := 0; y < a.height; y++ {
+					for x := 0; x < a.width; x++ {
+						tile := Coordinate{X: x, Y: y}
+						distance, ok := layer.field.Distance(tile)
+						if !ok || distance == 0 || layer.field.IsBlocked(tile) {
+							continue
+						}
+
+						direction, _ := layer.field.Direction(tile)
+						if direction == (vec2.T{0, 0}) || !reducesDistance(layer.field, tile, direction) {
+							t.Errorf("clearance %d: direction %v of tile %v at distance %d doesn't lead closer to players",
+								clearance, direction, tile, distance)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestClearanceLetsSmallEnemiesThroughNarrowGaps(t *testing.T) {
+	fixture := loadFixture(t, "narrow_gap")
+	a := fixture.algorithm(-1)
+	a.CreateDistancesMap()
+
+	small, big := a.enemies[100], a.enemies[101]
+	if small.direction == (vec2.T{0, 0}) {
+		t.Errorf("small enemy should go through the gap")
+	}
+	if big.direction != (vec2.T{0, 0}) {
+		t.Errorf("big enemy shouldn't fit through the gap, got direction %v", big.direction)
+	}
+}
+
+func TestClearanceForRadius(t *testing.T) {
+	cases := []struct {
+		radius    float64
+		clearance int
+	}{
+		{0, 0},
+		{0.375, 0},
+		{0.5, 0},
+		{0.75, 1},
+		{1.5, 1},
+		{1.6, 2},
+	}
+
+	for _, c := range cases {
+		if clearance := ClearanceForRadius(c.radius); clearance != c.clearance {
+			t.Errorf("radius %v: expected clearance %d, got %d", c.radius, c.clearance, clearance)
+		}
+	}
+}
+
+func TestSubTileEnemyFitsThroughOneTileCorridor(t *testing.T) {
+	// room split by a wall with one tile wide opening in the middle
+	width, height := 20, 7
+	collisions := make([]Coordinate, 0)
+	for x := 0; x < width; x++ {
+		collisions = append(collisions, Coordinate{X: x, Y: 0}, Coordinate{X: x, Y: height - 1})
+	}
+	for y := 0; y < height; y++ {
+		collisions = append(collisions, Coordinate{X: 0, Y: y}, Coordinate{X: width - 1, Y: y})
+		if y != height/2 {
+			collisions = append(collisions, Coordinate{X: width / 2, Y: y})
+		}
+	}
+
+	a := NewAIAlgorithm()
+	a.SetWidth(width)
+	a.SetHeight(height)
+	a.SetOffset(0, 0)
+	a.SetCollision(collisions)
+	a.SetSearchMargin(-1)
+	a.InitGraph()
+
+	// 12x12 pixel collision box fits inside a single 16 pixel tile
+	enemy := NewTestEnemy(100, 3, height/2)
+	enemy.SetClearance(ClearanceForRadius(12.0 / 2 / 16))
+	a.SetPlayers(map[uint32]Coordinate{1: {X: width - 4, Y: height / 2}})
+	a.SetEnemies(map[uint32]*Enemy{100: enemy})
+	a.CreateDistancesMap()
+
+	if _, ok := a.layer(enemy.clearance).field.Distance(a.toGrid(enemy.position)); !ok {
+		t.Fatalf("enemy with clearance %d has no path through the corridor", enemy.clearance)
+	}
+	if enemy.direction == (vec2.T{0, 0}) {
+		t.Errorf("enemy should walk towards the corridor")
+	}
+}
+
+func BenchmarkCreateDistancesMap(b *testing.B) {
+	sizes := []struct{ width, height int }{
+		{32, 24},
+		{64, 48},
+		{128, 96},
+		{256, 192},
+	}
+
+	for _, size := range sizes {
+		for _, mode := range searchModes {
+			name := fmt.Sprintf("%dx%d/%s", size.width, size.height, mode.name)
+			b.Run(name, func(b *testing.B) {
+				a := benchmarkRoom(b, size.width, size.height, mode.margin)
+				players := map[uint32]Coordinate{
+					1: {X: size.width/2 - 6, Y: size.height/2 + 6},
+					2: {X: size.width/2 + 14, Y: size.height/2 + 6},
+				}
+				a.SetPlayers(players)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// players walk back and forth by one tile, the usual change between two map updates
+					step := 1 - 2*(i/8%2)
+					players[1] = Coordinate{X: players[1].X + step, Y: players[1].Y}
+					players[2] = Coordinate{X: players[2].X, Y: players[2].Y + step}
+					a.CreateDistancesMap()
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCreateDistancesMapFixtures(b *testing.B) {
+	for _, name := range fixtureNames(b) {
+		for _, mode := range searchModes {
+			b.Run(name+"/"+mode.name, func(b *testing.B) {
+				fixture := loadFixture(b, name)
+				a := fixture.algorithm(mode.margin)
+				players := fixture.players()
+				a.SetPlayers(players)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					step := 1 - 2*(i/4%2)
+					for id, player := range players {
+						players[id] = Coordinate{X: player.X + step, Y: player.Y}
+					}
+					a.CreateDistancesMap()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package game_controllers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const FIXTURE_DIR = "testdata/rooms"
+
+// roomFixture is room layout loaded from testdata, every position is given in map coordinates
+type roomFixture struct {
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	Offset     [2]int            `json:"offset"`
+	Collisions [][2]int          `json:"collisions"`
+	Players    map[uint32][2]int `json:"players"`
+	Enemies    []enemyFixture    `json:"enemies"`
+	// enemies walled off from every player
+	Unreachable []uint32 `json:"unreachable"`
+}
+
+type enemyFixture struct {
+	ID       uint32 `json:"id"`
+	Position [2]int `json:"position"`
+	// default clearance is used when it's missing
+	Clearance *int `json:"clearance"`
+}
+
+// fixtureNames lists names of all room fixtures, so every test runs against every room
+func fixtureNames(tb testing.TB) []string {
+	tb.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(FIXTURE_DIR, "*.json"))
+	if err != nil {
+		tb.Fatalf("couldn't list fixtures: %v", err)
+	}
+	if len(paths) == 0 {
+		tb.Fatalf("no fixtures found in %s", FIXTURE_DIR)
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	return names
+}
+
+func loadFixture(tb testing.TB, name string) roomFixture {
+	tb.Helper()
+
+	data, err := os.ReadFile(filepath.Join(FIXTURE_DIR, name+".json"))
+	if err != nil {
+		tb.Fatalf("couldn't read fixture %s: %v", name, err)
+	}
+
+	fixture := roomFixture{}
+	if err = json.Unmarshal(data, &fixture); err != nil {
+		tb.Fatalf("couldn't parse fixture %s: %v", name, err)
+	}
+	return fixture
+}
+
+// algorithm builds algorithm for the room with players and enemies from the fixture,
+// negative margin searches the whole map
+func (f roomFixture) algorithm(margin int) *AIAlgorithm {
+	collisions := make([]Coordinate, 0, len(f.Collisions))
+	for _, collision := range f.Collisions {
+		collisions = append(collisions, Coordinate{X: collision[0], Y: collision[1]})
+	}
+
+	a := NewAIAlgorithm()
+	a.SetWidth(f.Width)
+	a.SetHeight(f.Height)
+	a.SetOffset(f.Offset[0], f.Offset[1])
+	a.SetCollision(collisions)
+	a.SetSearchMargin(margin)
+	a.InitGraph()
+
+	a.SetPlayers(f.players())
+	a.SetEnemies(f.enemies())
+	return a
+}
+
+func (f roomFixture) players() map[uint32]Coordinate {
+	players := make(map[uint32]Coordinate, len(f.Players))
+	for id, position := range f.Players {
+		players[id] = Coordinate{X: position[0], Y: position[1]}
+	}
+	return players
+}
+
+func (f roomFixture) enemies() map[uint32]*Enemy {
+	enemies := make(map[uint32]*Enemy, len(f.Enemies))
+	for _, enemy := range f.Enemies {
+		enemies[enemy.ID] = NewTestEnemy(enemy.ID, enemy.Position[0], enemy.Position[1])
+		if enemy.Clearance != nil {
+			enemies[enemy.ID].SetClearance(*enemy.Clearance)
+		}
+	}
+	return enemies
+}
+
+func (f roomFixture) isUnreachable(id uint32) bool {
+	return slices.Contains(f.Unreachable, id)
+}
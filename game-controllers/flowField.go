@@ -0,0 +1,326 @@
+package game_controllers
+
+import (
+	"math"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+const (
+	UNREACHABLE = math.MaxInt32
+	NO_OWNER    = math.MaxUint32
+)
+
+// FlowField keeps distance from every tile to the closest player. When players move only tiles
+// that were closest to their previous positions are computed again, the rest of the field stays as it was.
+// Tiles are given in grid coordinates, without the map offset. Grid is stored row by row in flat slices
+// and queues are kept between updates, so updating the field doesn't allocate once it's warmed up.
+type FlowField struct {
+	width, height int
+	blocked       []bool
+	distance      []int
+	// player the tile is the closest to
+	owner   []uint32
+	sources map[uint32]Coordinate
+	// distances are spread only inside these tiles
+	minBound, maxBound Coordinate
+
+	queue   bucketQueue
+	flood   Queue
+	cleared []int
+}
+
+func NewFlowField(width, height int) *FlowField {
+	f := &FlowField{
+		width:    width,
+		height:   height,
+		blocked:  make([]bool, width*height),
+		distance: make([]int, width*height),
+		owner:    make([]uint32, width*height),
+		sources:  make(map[uint32]Coordinate),
+		minBound: Coordinate{X: 0, Y: 0},
+		maxBound: Coordinate{X: width - 1, Y: height - 1},
+	}
+	f.Reset()
+	return f
+}
+
+// NewLayer creates empty field with the same walls, walls are shared between both fields
+func (f *FlowField) NewLayer() *FlowField {
+	layer := &FlowField{
+		width:    f.width,
+		height:   f.height,
+		blocked:  f.blocked,
+		distance: make([]int, len(f.distance)),
+		owner:    make([]uint32, len(f.owner)),
+		sources:  make(map[uint32]Coordinate),
+		minBound: f.minBound,
+		maxBound: f.maxBound,
+	}
+	layer.Reset()
+	return layer
+}
+
+// Reset forgets all players, next Update computes the whole field from scratch
+func (f *FlowField) Reset() {
+	for i := range f.distance {
+		f.distance[i] = UNREACHABLE
+		f.owner[i] = NO_OWNER
+	}
+	clear(f.sources)
+}
+
+// SetBlocked marks tile as not walkable, it should be called before the first Update
+func (f *FlowField) SetBlocked(tile Coordinate) {
+	if f.contains(tile) {
+		f.blocked[f.index(tile)] = true
+	}
+}
+
+// SetBounds limits search to the rectangle between given tiles, its edges work like edges of the map.
+// Distances computed for other bounds are forgotten.
+func (f *FlowField) SetBounds(minBound, maxBound Coordinate) {
+	minBound = Coordinate{X: max(0, minBound.X), Y: max(0, minBound.Y)}
+	maxBound = Coordinate{X: min(f.width-1, maxBound.X), Y: min(f.height-1, maxBound.Y)}
+	if minBound == f.minBound && maxBound == f.maxBound {
+		return
+	}
+
+	f.minBound = minBound
+	f.maxBound = maxBound
+	f.Reset()
+}
+
+// RemoveBounds lets search go through the whole map
+func (f *FlowField) RemoveBounds() {
+	f.SetBounds(Coordinate{X: 0, Y: 0}, Coordinate{X: f.width - 1, Y: f.height - 1})
+}
+
+func (f *FlowField) IsBlocked(tile Coordinate) bool {
+	return f.contains(tile) && f.blocked[f.index(tile)]
+}
+
+// Distance returns number of steps from tile to the closest player
+func (f *FlowField) Distance(tile Coordinate) (int, bool) {
+	if !f.contains(tile) || f.distance[f.index(tile)] == UNREACHABLE {
+		return 0, false
+	}
+	return f.distance[f.index(tile)], true
+}
+
+// Owner returns the player closest to the tile, NO_OWNER when no player can be reached from it
+func (f *FlowField) Owner(tile Coordinate) uint32 {
+	if !f.contains(tile) {
+		return NO_OWNER
+	}
+	return f.owner[f.index(tile)]
+}
+
+// Update moves players to given tiles. Tiles closest to players that moved or left are cleared
+// and filled again from the tiles around them, then players at new positions spread their distances
+// as long as they are closer than before.
+func (f *FlowField) Update(sources map[uint32]Coordinate) {
+	for id, previous := range f.sources {
+		if tile, ok := sources[id]; ok && tile == previous {
+			continue
+		}
+		f.removeSource(id, previous)
+		delete(f.sources, id)
+	}
+
+	// players that didn't move are seeded again as well, their tile might have been cleared
+	// when another player standing on it left
+	for id, tile := range sources {
+		if !f.inBounds(tile) {
+			continue
+		}
+		f.sources[id] = tile
+		f.relax(f.index(tile), 0, id)
+	}
+
+	f.propagate()
+}
+
+// removeSource clears every tile that was the closest to the player and queues tiles around
+// the cleared area, so the area can be filled from them
+func (f *FlowField) removeSource(id uint32, tile Coordinate) {
+	if !f.contains(tile) || f.owner[f.index(tile)] != id {
+		return
+	}
+
+	var neighbors [4]int
+	f.cleared = f.cleared[:0]
+	f.flood.clear()
+	start := f.index(tile)
+	f.flood.put(start)
+	f.distance[start] = UNREACHABLE
+	f.owner[start] = NO_OWNER
+
+	for !f.flood.isEmpty() {
+		current, _ := f.flood.get()
+		f.cleared = append(f.cleared, current)
+
+		for _, next := range neighbors[:f.neighbors(current, &neighbors)] {
+			if f.owner[next] == id {
+				f.distance[next] = UNREACHABLE
+				f.owner[next] = NO_OWNER
+				f.flood.put(next)
+			}
+		}
+	}
+
+	for _, current := range f.cleared {
+		for _, next := range neighbors[:f.neighbors(current, &neighbors)] {
+			// blocked tiles have owner only when a player stands on them
+			if f.owner[next] != NO_OWNER {
+				f.queue.push(next, f.distance[next])
+			}
+		}
+	}
+}
+
+// propagate spreads distances from queued tiles, tiles are processed from the closest one,
+// so every tile gets its final distance the first time it's taken from the queue
+func (f *FlowField) propagate() {
+	var neighbors [4]int
+	for {
+		current, distance, ok := f.queue.pop()
+		if !ok {
+			return
+		}
+		// tile got closer or was cleared after it had been queued
+		if f.distance[current] != distance {
+			continue
+		}
+
+		owner := f.owner[current]
+		for _, next := range neighbors[:f.neighbors(current, &neighbors)] {
+			if !f.blocked[next] {
+				f.relax(next, distance+1, owner)
+			}
+		}
+	}
+}
+
+func (f *FlowField) relax(index, distance int, owner uint32) {
+	if distance < f.distance[index] {
+		f.distance[index] = distance
+		f.owner[index] = owner
+		f.queue.push(index, distance)
+	}
+}
+
+// Direction returns normalized vector pointing towards the closest player. Enemies standing
+// on blocked tiles or next to the player don't move, tiles without path to any player have no direction.
+func (f *FlowField) Direction(tile Coordinate) (vec2.T, bool) {
+	distance, ok := f.Distance(tile)
+	if f.IsBlocked(tile) {
+		return vec2.T{0, 0}, true
+	}
+	if !ok {
+		return vec2.T{}, false
+	}
+	if distance == 0 {
+		return vec2.T{0, 0}, true
+	}
+
+	up, upOk := f.neighborDistance(tile, Coordinate{X: tile.X, Y: tile.Y - 1})
+	down, downOk := f.neighborDistance(tile, Coordinate{X: tile.X, Y: tile.Y + 1})
+	left, leftOk := f.neighborDistance(tile, Coordinate{X: tile.X - 1, Y: tile.Y})
+	right, rightOk := f.neighborDistance(tile, Coordinate{X: tile.X + 1, Y: tile.Y})
+
+	move := vec2.T{
+		float32(-axisGradient(left, right, distance, leftOk, rightOk)),
+		float32(axisGradient(up, down, distance, upOk, downOk)),
+	}
+	if move == (vec2.T{0, 0}) {
+		// paths of the same length go both ways around a wall or to two players,
+		// distances cancel out, so enemy takes the path towards its closest player
+		return f.descent(tile, distance), true
+	}
+	return *move.Normalize(), true
+}
+
+// descent returns direction to neighbor closer to players, neighbors closest
+// to the same player as the tile are preferred
+func (f *FlowField) descent(tile Coordinate, distance int) vec2.T {
+	index := f.index(tile)
+	best, bestOwned := -1, false
+	var neighbors [4]int
+	for _, next := range neighbors[:f.neighbors(index, &neighbors)] {
+		if f.distance[next] >= distance {
+			continue
+		}
+		owned := f.owner[next] == f.owner[index]
+		if best < 0 || owned && !bestOwned {
+			best, bestOwned = next, owned
+		}
+	}
+
+	if best < 0 {
+		return vec2.T{0, 0}
+	}
+	// directions have y axis pointing up
+	return vec2.T{float32(best%f.width - tile.X), float32(tile.Y - best/f.width)}
+}
+
+// axisGradient returns how much distance grows along the axis, wall on one side
+// is treated as if it was one step further than the tile itself
+func axisGradient(before, after, distance int, beforeOk, afterOk bool) int {
+	switch {
+	case !beforeOk && !afterOk:
+		return 0
+	case !beforeOk:
+		return after - distance - 1
+	case !afterOk:
+		return distance - before + 1
+	default:
+		return after - before
+	}
+}
+
+// neighborDistance returns distance of neighbor, tiles outside the map have distance of the tile next to them
+func (f *FlowField) neighborDistance(tile, neighbor Coordinate) (int, bool) {
+	if !f.inBounds(neighbor) {
+		neighbor = tile
+	}
+	if f.blocked[f.index(neighbor)] {
+		return 0, false
+	}
+	return f.Distance(neighbor)
+}
+
+// neighbors writes indices of tiles next to the tile inside the search bounds and returns their count
+func (f *FlowField) neighbors(index int, neighbors *[4]int) int {
+	x, y := index%f.width, index/f.width
+	count := 0
+	if y > f.minBound.Y {
+		neighbors[count] = index - f.width
+		count++
+	}
+	if x > f.minBound.X {
+		neighbors[count] = index - 1
+		count++
+	}
+	if y < f.maxBound.Y {
+		neighbors[count] = index + f.width
+		count++
+	}
+	if x < f.maxBound.X {
+		neighbors[count] = index + 1
+		count++
+	}
+	return count
+}
+
+func (f *FlowField) index(tile Coordinate) int {
+	return tile.Y*f.width + tile.X
+}
+
+func (f *FlowField) contains(tile Coordinate) bool {
+	return tile.X >= 0 && tile.X < f.width && tile.Y >= 0 && tile.Y < f.height
+}
+
+func (f *FlowField) inBounds(tile Coordinate) bool {
+	return tile.X >= f.minBound.X && tile.X <= f.maxBound.X && tile.Y >= f.minBound.Y && tile.Y <= f.maxBound.Y
+}
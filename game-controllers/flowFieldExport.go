@@ -0,0 +1,209 @@
+package game_controllers
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"slices"
+)
+
+// FLOW_FIELD_PNG_SCALE is size of one tile in exported image in pixels
+const FLOW_FIELD_PNG_SCALE = 8
+
+var (
+	wallColor        = color.RGBA{0, 0, 0, 255}
+	blockedColor     = color.RGBA{60, 60, 60, 255}
+	unreachableColor = color.RGBA{80, 0, 0, 255}
+	outsideColor     = color.RGBA{30, 30, 45, 255}
+	nearColor        = color.RGBA{255, 230, 0, 255}
+	farColor         = color.RGBA{0, 40, 160, 255}
+	directionColor   = color.RGBA{255, 255, 255, 255}
+	playerColor      = color.RGBA{0, 220, 0, 255}
+	enemyColor       = color.RGBA{230, 0, 0, 255}
+)
+
+// FlowFieldSnapshot is state of the flow field of one clearance at a single update,
+// grid values are stored row by row and coordinates of walls, players and enemies are map coordinates
+type FlowFieldSnapshot struct {
+	Tick      uint64     `json:"tick"`
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	OffsetX   int        `json:"offsetX"`
+	OffsetY   int        `json:"offsetY"`
+	Clearance int        `json:"clearance"`
+	SearchMin Coordinate `json:"searchMin"`
+	SearchMax Coordinate `json:"searchMax"`
+	// -1 marks tiles without path to any player
+	Distances  []int                 `json:"distances"`
+	Directions [][2]float32          `json:"directions"`
+	Blocked    []bool                `json:"blocked"`
+	Walls      []Coordinate          `json:"walls"`
+	Players    map[uint32]Coordinate `json:"players"`
+	Enemies    []EnemySnapshot       `json:"enemies"`
+}
+
+type EnemySnapshot struct {
+	ID        uint32     `json:"id"`
+	Name      string     `json:"name"`
+	Position  Coordinate `json:"position"`
+	Direction [2]float32 `json:"direction"`
+	// omitted when enemy has no target
+	Target    *uint32 `json:"target,omitempty"`
+	Clearance int     `json:"clearance"`
+}
+
+// Snapshots copies flow fields of every clearance used in the room, enemies are included in snapshot
+// of their clearance. Caller has to hold at least read lock of the algorithm.
+func (a *AIAlgorithm) Snapshots() []FlowFieldSnapshot {
+	clearances := make([]int, 0, len(a.layers))
+	for clearance := range a.layers {
+		clearances = append(clearances, clearance)
+	}
+	slices.Sort(clearances)
+
+	snapshots := make([]FlowFieldSnapshot, 0, len(clearances))
+	for _, clearance := range clearances {
+		snapshots = append(snapshots, a.snapshot(clearance))
+	}
+	return snapshots
+}
+
+func (a *AIAlgorithm) snapshot(clearance int) FlowFieldSnapshot {
+	field := a.layers[clearance].field
+	snapshot := FlowFieldSnapshot{
+		Tick:       a.tick,
+		Width:      a.width,
+		Height:     a.height,
+		OffsetX:    a.offsetWidth,
+		OffsetY:    a.offsetHeight,
+		Clearance:  clearance,
+		SearchMin:  a.searchMin,
+		SearchMax:  a.searchMax,
+		Distances:  make([]int, 0, a.width*a.height),
+		Directions: make([][2]float32, 0, a.width*a.height),
+		Blocked:    make([]bool, 0, a.width*a.height),
+		Walls:      make([]Coordinate, 0, len(a.obstacles)),
+		Players:    make(map[uint32]Coordinate, len(a.players)),
+		Enemies:    make([]EnemySnapshot, 0),
+	}
+
+	for y := 0; y < a.height; y++ {
+		for x := 0; x < a.width; x++ {
+			tile := Coordinate{X: x, Y: y}
+			distance, ok := field.Distance(tile)
+			if !ok {
+				distance = -1
+			}
+			direction, _ := field.Direction(tile)
+			snapshot.Distances = append(snapshot.Distances, distance)
+			snapshot.Directions = append(snapshot.Directions, [2]float32{direction[0], direction[1]})
+			snapshot.Blocked = append(snapshot.Blocked, field.IsBlocked(tile))
+		}
+	}
+	for wall := range a.obstacles {
+		snapshot.Walls = append(snapshot.Walls, wall)
+	}
+	for id, player := range a.players {
+		snapshot.Players[id] = player
+	}
+	for _, enemy := range a.enemies {
+		if enemy.clearance != clearance {
+			continue
+		}
+		enemySnapshot := EnemySnapshot{
+			ID:        enemy.id,
+			Name:      enemy.name,
+			Position:  enemy.position,
+			Direction: [2]float32{enemy.direction[0], enemy.direction[1]},
+			Clearance: enemy.clearance,
+		}
+		if target, ok := enemy.GetTarget(); ok {
+			enemySnapshot.Target = &target
+		}
+		snapshot.Enemies = append(snapshot.Enemies, enemySnapshot)
+	}
+	slices.SortFunc(snapshot.Enemies, func(a, b EnemySnapshot) int {
+		return int(a.ID) - int(b.ID)
+	})
+	return snapshot
+}
+
+func (s FlowFieldSnapshot) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WritePNG draws distances as heatmap going from yellow next to players to blue far from them,
+// with direction of every tile drawn as a white line from its center
+func (s FlowFieldSnapshot) WritePNG(w io.Writer) error {
+	img := image.NewRGBA(image.Rect(0, 0, s.Width*FLOW_FIELD_PNG_SCALE, s.Height*FLOW_FIELD_PNG_SCALE))
+
+	maxDistance := 1
+	for _, distance := range s.Distances {
+		maxDistance = max(maxDistance, distance)
+	}
+	walls := make(map[Coordinate]bool, len(s.Walls))
+	for _, wall := range s.Walls {
+		walls[Coordinate{X: wall.X - s.OffsetX, Y: wall.Y - s.OffsetY}] = true
+	}
+
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			tile := Coordinate{X: x, Y: y}
+			index := y*s.Width + x
+			distance := s.Distances[index]
+			switch {
+			case walls[tile]:
+				s.fillTile(img, tile, wallColor)
+			case s.Blocked[index]:
+				s.fillTile(img, tile, blockedColor)
+			case x < s.SearchMin.X || x > s.SearchMax.X || y < s.SearchMin.Y || y > s.SearchMax.Y:
+				s.fillTile(img, tile, outsideColor)
+			case distance < 0:
+				s.fillTile(img, tile, unreachableColor)
+			default:
+				s.fillTile(img, tile, lerpColor(nearColor, farColor, float64(distance)/float64(maxDistance)))
+				s.drawDirection(img, tile, s.Directions[index])
+			}
+		}
+	}
+
+	for _, player := range s.Players {
+		s.fillTile(img, Coordinate{X: player.X - s.OffsetX, Y: player.Y - s.OffsetY}, playerColor)
+	}
+	for _, enemy := range s.Enemies {
+		tile := Coordinate{X: enemy.Position.X - s.OffsetX, Y: enemy.Position.Y - s.OffsetY}
+		s.fillTile(img, tile, enemyColor)
+		s.drawDirection(img, tile, enemy.Direction)
+	}
+	return png.Encode(w, img)
+}
+
+func (s FlowFieldSnapshot) fillTile(img *image.RGBA, tile Coordinate, c color.RGBA) {
+	for dy := 0; dy < FLOW_FIELD_PNG_SCALE; dy++ {
+		for dx := 0; dx < FLOW_FIELD_PNG_SCALE; dx++ {
+			img.SetRGBA(tile.X*FLOW_FIELD_PNG_SCALE+dx, tile.Y*FLOW_FIELD_PNG_SCALE+dy, c)
+		}
+	}
+}
+
+// drawDirection draws line from center of the tile, directions have y axis pointing up like on the client
+func (s FlowFieldSnapshot) drawDirection(img *image.RGBA, tile Coordinate, direction [2]float32) {
+	centerX := float32(tile.X*FLOW_FIELD_PNG_SCALE + FLOW_FIELD_PNG_SCALE/2)
+	centerY := float32(tile.Y*FLOW_FIELD_PNG_SCALE + FLOW_FIELD_PNG_SCALE/2)
+	for step := 0; step < FLOW_FIELD_PNG_SCALE/2; step++ {
+		x := centerX + direction[0]*float32(step)
+		y := centerY - direction[1]*float32(step)
+		img.SetRGBA(int(x), int(y), directionColor)
+	}
+}
+
+func lerpColor(from, to color.RGBA, t float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 255}
+}
@@ -0,0 +1,73 @@
+package game_controllers
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// walkableTiles lists tiles players can stand on, in grid coordinates
+func walkableTiles(a *AIAlgorithm) []Coordinate {
+	tiles := make([]Coordinate, 0)
+	for y := 0; y < a.height; y++ {
+		for x := 0; x < a.width; x++ {
+			tile := Coordinate{X: x + a.offsetWidth, Y: y + a.offsetHeight}
+			if !a.IsObstacle(tile) {
+				tiles = append(tiles, tile)
+			}
+		}
+	}
+	return tiles
+}
+
+func TestFlowFieldRepairMatchesFullSearch(t *testing.T) {
+	for _, name := range fixtureNames(t) {
+		t.Run(name, func(t *testing.T) {
+			fixture := loadFixture(t, name)
+			repaired := fixture.algorithm(-1)
+			tiles := walkableTiles(repaired)
+			random := rand.New(rand.NewPCG(1, 2))
+			players := fixture.players()
+			repaired.SetPlayers(players)
+
+			for step := 0; step < 100; step++ {
+				// players mostly take small steps, sometimes one of them jumps somewhere else or leaves
+				for id, player := range players {
+					next := Coordinate{X: player.X + random.IntN(3) - 1, Y: player.Y + random.IntN(3) - 1}
+					if !repaired.IsObstacle(next) && repaired.isInside(next) {
+						players[id] = next
+					}
+				}
+				switch random.IntN(20) {
+				case 0:
+					players[uint32(random.IntN(3)+1)] = tiles[random.IntN(len(tiles))]
+				case 1:
+					if len(players) > 1 {
+						delete(players, uint32(random.IntN(3)+1))
+					}
+				}
+				repaired.CreateDistancesMap()
+
+				full := fixture.algorithm(-1)
+				full.SetPlayers(players)
+				full.CreateDistancesMap()
+				for clearance, layer := range full.layers {
+					if distances := repaired.layer(clearance).field.distance; !equalDistances(distances, layer.field.distance) {
+						t.Fatalf("step %d: repaired distances of clearance %d differ from full search", step, clearance)
+					}
+				}
+			}
+		})
+	}
+}
+
+func equalDistances(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package game_controllers
+
+import "log"
+
+// Grid is collision layout of a room prepared for pathfinding. It's built once per room
+// and handed to the algorithm every time players enter the room.
+type Grid struct {
+	width, height, offsetWidth, offsetHeight int
+	obstacles                                map[Coordinate]bool
+	// free tiles between every tile and the nearest wall, -1 for walls
+	clearance []int
+	// flow fields are created when enemies need them and kept with the grid,
+	// distances in them are forgotten whenever the grid is used again
+	layers map[int]*pathLayer
+}
+
+func NewGrid(width, height, offsetWidth, offsetHeight int, collisions []Coordinate) *Grid {
+	grid := &Grid{
+		width:        width,
+		height:       height,
+		offsetWidth:  offsetWidth,
+		offsetHeight: offsetHeight,
+		obstacles:    make(map[Coordinate]bool, len(collisions)),
+		layers:       make(map[int]*pathLayer),
+	}
+	for _, coll := range collisions {
+		grid.obstacles[coll] = true
+	}
+	grid.computeClearance()
+
+	log.Printf("Created graph, width: %d, height: %d\n", width, height)
+	return grid
+}
+
+// SetGrid switches algorithm to the room of the grid, distances computed in the room before are forgotten.
+// Grid can be used by one algorithm at a time.
+func (a *AIAlgorithm) SetGrid(grid *Grid) {
+	a.width = grid.width
+	a.height = grid.height
+	a.offsetWidth = grid.offsetWidth
+	a.offsetHeight = grid.offsetHeight
+	a.obstacles = grid.obstacles
+	a.clearance = grid.clearance
+	a.layers = grid.layers
+
+	a.ClearGraph()
+	a.bounded = false
+	a.unboundedEnemies = -1
+	a.removeSearchBounds()
+}
+
+// computeClearance finds number of free tiles between every tile and the nearest wall, diagonal steps count
+// as one tile, so enemy with clearance c fits on the tile when no wall lies in the square of 2c+1 tiles around it
+func (grid *Grid) computeClearance() {
+	grid.clearance = make([]int, grid.width*grid.height)
+	queue := Queue{}
+	for i := range grid.clearance {
+		grid.clearance[i] = UNREACHABLE
+	}
+	for obstacle := range grid.obstacles {
+		x, y := obstacle.X-grid.offsetWidth, obstacle.Y-grid.offsetHeight
+		if x >= 0 && x < grid.width && y >= 0 && y < grid.height {
+			index := y*grid.width + x
+			grid.clearance[index] = -1
+			queue.put(index)
+		}
+	}
+
+	for !queue.isEmpty() {
+		current, _ := queue.get()
+		x, y := current%grid.width, current/grid.width
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := x+dx, y+dy
+				if nx < 0 || nx >= grid.width || ny < 0 || ny >= grid.height {
+					continue
+				}
+				next := ny*grid.width + nx
+				if grid.clearance[next] == UNREACHABLE {
+					grid.clearance[next] = grid.clearance[current] + 1
+					queue.put(next)
+				}
+			}
+		}
+	}
+}
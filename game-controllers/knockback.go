@@ -0,0 +1,77 @@
+package game_controllers
+
+import (
+	"math"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+const (
+	// knockback velocity drops exponentially by this factor every second
+	KNOCKBACK_DAMPING = 8.0
+	// knockback slower than this many tiles per second is over
+	KNOCKBACK_MIN_SPEED = 0.5
+)
+
+// ApplyKnockback pushes enemy with impulse given in tiles per second
+func (e *Enemy) ApplyKnockback(impulse vec2.T) {
+	e.knockback.Add(&impulse)
+}
+
+// IsKnockedBack reports whether enemy is moved by knockback instead of walking on its own
+func (e *Enemy) IsKnockedBack() bool {
+	return e.knockback != vec2.T{0, 0}
+}
+
+// UpdateKnockback moves enemy along its knockback velocity for dt seconds one tile at a time, so enemy stops
+// at the first wall or tile too close to walls for its clearance, where flow field couldn't move it anymore.
+// It reports whether enemy changed tile.
+// Caller has to hold at least read lock of the algorithm.
+func (a *AIAlgorithm) UpdateKnockback(e *Enemy, dt float64) bool {
+	if !e.IsKnockedBack() {
+		return false
+	}
+
+	e.knockbackOffset[0] += e.knockback[0] * float32(dt)
+	e.knockbackOffset[1] += e.knockback[1] * float32(dt)
+
+	moved := false
+	for axis := 0; axis < 2; axis++ {
+		for math.Abs(float64(e.knockbackOffset[axis])) >= 1 {
+			step := Coordinate{}
+			if axis == 0 {
+				step.X = sign(int(e.knockbackOffset[axis]))
+			} else {
+				step.Y = sign(int(e.knockbackOffset[axis]))
+			}
+
+			next := Coordinate{X: e.position.X + step.X, Y: e.position.Y + step.Y}
+			if a.IsObstacle(next) || !a.isInside(next) || a.isBlockedFor(next, e.clearance) {
+				e.knockback[axis] = 0
+				e.knockbackOffset[axis] = 0
+				break
+			}
+
+			e.position = next
+			e.knockbackOffset[axis] -= float32(step.X + step.Y)
+			moved = true
+		}
+	}
+
+	e.knockback.Scale(float32(math.Exp(-KNOCKBACK_DAMPING * dt)))
+	if e.knockback.Length() < KNOCKBACK_MIN_SPEED {
+		e.knockback = vec2.T{0, 0}
+		e.knockbackOffset = vec2.T{0, 0}
+	}
+	return moved
+}
+
+// isInside reports whether tile lies on the map, map of unknown size contains every tile
+func (a *AIAlgorithm) isInside(tile Coordinate) bool {
+	if a.width == 0 || a.height == 0 {
+		return true
+	}
+	x := tile.X - a.offsetWidth
+	y := tile.Y - a.offsetHeight
+	return x >= 0 && y >= 0 && x < a.width && y < a.height
+}
@@ -0,0 +1,46 @@
+package game_controllers
+
+import (
+	"testing"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+func TestKnockbackStopsBeforeBlockedTiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		walls     []Coordinate
+		clearance int
+		impulse   vec2.T
+		expected  Coordinate
+	}{
+		{"short push", nil, 0, vec2.T{3, 0}, Coordinate{X: 5, Y: 5}},
+		{"stops at room wall", nil, 0, vec2.T{100, 0}, Coordinate{X: 10, Y: 5}},
+		{"stops at inner wall", []Coordinate{{X: 7, Y: 5}}, 0, vec2.T{100, 0}, Coordinate{X: 6, Y: 5}},
+		// big enemy can't get closer to walls than the flow field would let it walk
+		{"stops where clearance ends", nil, 2, vec2.T{100, 0}, Coordinate{X: 8, Y: 5}},
+		// knockback uses tile coordinates, rows grow downwards
+		{"pushed down", nil, 0, vec2.T{0, 100}, Coordinate{X: 5, Y: 10}},
+		{"pushed up and left", nil, 0, vec2.T{-100, -100}, Coordinate{X: 1, Y: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := walledRoom(t, 12, test.walls...)
+			enemy := NewTestEnemy(1, 5, 5)
+			enemy.SetClearance(test.clearance)
+			enemy.ApplyKnockback(test.impulse)
+
+			for i := 0; i < 100 && enemy.IsKnockedBack(); i++ {
+				a.UpdateKnockback(enemy, 0.05)
+			}
+
+			if enemy.IsKnockedBack() {
+				t.Errorf("knockback didn't end")
+			}
+			if enemy.position != test.expected {
+				t.Errorf("enemy stopped at %v, expected %v", enemy.position, test.expected)
+			}
+		})
+	}
+}
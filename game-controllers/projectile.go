@@ -0,0 +1,100 @@
+package game_controllers
+
+import (
+	"math"
+
+	"github.com/ungerik/go3d/vec2"
+	u "server/utils"
+)
+
+// longest distance in tiles projectile can travel between two collision checks, so fast projectiles
+// don't jump over thin walls or players
+const PROJECTILE_MAX_STEP = 0.5
+
+// Projectile flies in straight line with constant speed until it hits a wall, a player or its lifetime ends
+type Projectile struct {
+	id, ownerId uint32
+	name        string
+	x, y        float64
+	velocity    vec2.T
+	lifetime    float64
+	damage      float64
+	radius      float64
+}
+
+// NewProjectile creates projectile shot by enemy towards target
+func NewProjectile(id uint32, owner *Enemy, target Coordinate, data u.RangedData) *Projectile {
+	direction := vec2.T{
+		float32(target.X - owner.position.X),
+		float32(target.Y - owner.position.Y),
+	}
+	direction.Normalize()
+
+	return &Projectile{
+		id:       id,
+		ownerId:  owner.id,
+		name:     data.Projectile,
+		x:        float64(owner.position.X),
+		y:        float64(owner.position.Y),
+		velocity: direction.Scaled(float32(data.Speed)),
+		lifetime: data.Lifetime,
+		damage:   data.Damage,
+		radius:   data.Radius,
+	}
+}
+
+// Update moves projectile by dt seconds. It returns id of player that got hit, whether any player got hit
+// and whether projectile still flies. Caller has to hold at least read lock of the algorithm.
+func (p *Projectile) Update(dt float64, a *AIAlgorithm, players map[uint32]Coordinate) (uint32, bool, bool) {
+	distance := dt * math.Hypot(float64(p.velocity[0]), float64(p.velocity[1]))
+	steps := max(1, int(math.Ceil(distance/PROJECTILE_MAX_STEP)))
+	stepTime := dt / float64(steps)
+
+	for i := 0; i < steps; i++ {
+		p.x += float64(p.velocity[0]) * stepTime
+		p.y += float64(p.velocity[1]) * stepTime
+		p.lifetime -= stepTime
+
+		if a.IsObstacle(p.GetTile()) {
+			return 0, false, false
+		}
+		for playerId, player := range players {
+			if math.Hypot(float64(player.X)-p.x, float64(player.Y)-p.y) <= p.radius {
+				return playerId, true, false
+			}
+		}
+		if p.lifetime <= 0 {
+			return 0, false, false
+		}
+	}
+	return 0, false, true
+}
+
+// GetTile returns tile the projectile is currently in
+func (p *Projectile) GetTile() Coordinate {
+	return Coordinate{X: int(math.Round(p.x)), Y: int(math.Round(p.y))}
+}
+
+func (p *Projectile) GetId() uint32 {
+	return p.id
+}
+
+func (p *Projectile) GetOwnerId() uint32 {
+	return p.ownerId
+}
+
+func (p *Projectile) GetName() string {
+	return p.name
+}
+
+func (p *Projectile) GetPosition() (float64, float64) {
+	return p.x, p.y
+}
+
+func (p *Projectile) GetVelocity() vec2.T {
+	return p.velocity
+}
+
+func (p *Projectile) GetDamage() float64 {
+	return p.damage
+}
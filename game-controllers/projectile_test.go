@@ -0,0 +1,80 @@
+package game_controllers
+
+import (
+	"testing"
+
+	u "server/utils"
+)
+
+// walledRoom builds size x size room surrounded by walls with extra walls inside
+func walledRoom(t *testing.T, size int, walls ...Coordinate) *AIAlgorithm {
+	t.Helper()
+
+	collisions := append([]Coordinate{}, walls...)
+	for i := 0; i < size; i++ {
+		collisions = append(collisions,
+			Coordinate{X: i, Y: 0}, Coordinate{X: i, Y: size - 1},
+			Coordinate{X: 0, Y: i}, Coordinate{X: size - 1, Y: i},
+		)
+	}
+
+	a := NewAIAlgorithm()
+	a.SetWidth(size)
+	a.SetHeight(size)
+	a.SetOffset(0, 0)
+	a.SetCollision(collisions)
+	a.InitGraph()
+	return a
+}
+
+func TestProjectileUpdate(t *testing.T) {
+	data := u.RangedData{Projectile: "Arrow", Speed: 10, Lifetime: 2, Damage: 5, Radius: 0.5}
+
+	tests := []struct {
+		name    string
+		walls   []Coordinate
+		players map[uint32]Coordinate
+		data    u.RangedData
+		dt      float64
+		hit     uint32
+		alive   bool
+	}{
+		{"hits player in line", nil, map[uint32]Coordinate{3: {X: 8, Y: 5}}, data, 1, 3, false},
+		{"hits the first player on the way", nil, map[uint32]Coordinate{3: {X: 9, Y: 5}, 4: {X: 6, Y: 5}}, data, 1, 4, false},
+		{"flies past players aside", nil, map[uint32]Coordinate{3: {X: 5, Y: 7}}, data, 0.2, 0, true},
+		{"stops at wall", []Coordinate{{X: 6, Y: 5}}, map[uint32]Coordinate{3: {X: 8, Y: 5}}, data, 1, 0, false},
+		// one update covers the whole room, steps are short enough not to skip a single wall
+		{"fast projectile doesn't skip wall", []Coordinate{{X: 7, Y: 5}}, map[uint32]Coordinate{3: {X: 9, Y: 5}},
+			u.RangedData{Speed: 100, Lifetime: 2, Radius: 0.2}, 1, 0, false},
+		{"lifetime ends", nil, map[uint32]Coordinate{}, u.RangedData{Speed: 1, Lifetime: 0.5, Radius: 0.5}, 1, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := walledRoom(t, 12, test.walls...)
+			owner := NewTestEnemy(1, 4, 5)
+			// shot towards the right wall
+			projectile := NewProjectile(100, owner, Coordinate{X: 10, Y: 5}, test.data)
+
+			playerId, hit, alive := projectile.Update(test.dt, a, test.players)
+			if hit != (test.hit != 0) || playerId != test.hit || alive != test.alive {
+				t.Errorf("projectile hit player %d (%v), alive: %v; expected player %d, alive: %v",
+					playerId, hit, alive, test.hit, test.alive)
+			}
+		})
+	}
+}
+
+func TestProjectileFliesTowardsTarget(t *testing.T) {
+	owner := NewTestEnemy(1, 2, 2)
+	projectile := NewProjectile(100, owner, Coordinate{X: 2, Y: 8}, u.RangedData{Speed: 4, Lifetime: 5, Radius: 0.1})
+	a := walledRoom(t, 12)
+
+	projectile.Update(0.5, a, map[uint32]Coordinate{})
+	if x, y := projectile.GetPosition(); x != 2 || y != 4 {
+		t.Errorf("projectile moved to (%v, %v), expected (2, 4)", x, y)
+	}
+	if tile := projectile.GetTile(); tile != (Coordinate{X: 2, Y: 4}) {
+		t.Errorf("projectile is in tile %v", tile)
+	}
+}
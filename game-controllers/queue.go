@@ -1,25 +1,75 @@
 package game_controllers
 
+// Queue is FIFO ring buffer of cell indices, its storage grows when it's full and is kept for the next search
 type Queue struct {
-	elements []Coordinate
+	elements   []int
+	head, size int
 }
 
-func (q *Queue) put(element Coordinate) {
-	q.elements = append(q.elements, element)
+func (q *Queue) put(element int) {
+	if q.size == len(q.elements) {
+		q.grow()
+	}
+	q.elements[(q.head+q.size)%len(q.elements)] = element
+	q.size++
 }
 
-func (q *Queue) get() (Coordinate, bool) {
+func (q *Queue) get() (int, bool) {
 	if q.isEmpty() {
-		return Coordinate{0, 0}, false
+		return 0, false
 	}
-	value := q.elements[0]
-	q.elements = q.elements[1:]
+	value := q.elements[q.head]
+	q.head = (q.head + 1) % len(q.elements)
+	q.size--
 	return value, true
 }
 
 func (q *Queue) isEmpty() bool {
-	if len(q.elements) == 0 {
-		return true
+	return q.size == 0
+}
+
+func (q *Queue) clear() {
+	q.head = 0
+	q.size = 0
+}
+
+func (q *Queue) grow() {
+	elements := make([]int, max(16, 2*len(q.elements)))
+	for i := 0; i < q.size; i++ {
+		elements[i] = q.elements[(q.head+i)%len(q.elements)]
+	}
+	q.elements = elements
+	q.head = 0
+}
+
+// bucketQueue returns cell indices ordered by distance, distances pushed while popping
+// can't be smaller than the last popped one. Buckets are kept for the next search.
+type bucketQueue struct {
+	buckets [][]int
+	current int
+	size    int
+}
+
+func (q *bucketQueue) push(index, distance int) {
+	for len(q.buckets) <= distance {
+		q.buckets = append(q.buckets, nil)
+	}
+	q.buckets[distance] = append(q.buckets[distance], index)
+	q.current = min(q.current, distance)
+	q.size++
+}
+
+func (q *bucketQueue) pop() (int, int, bool) {
+	for q.size > 0 {
+		bucket := q.buckets[q.current]
+		if len(bucket) > 0 {
+			index := bucket[len(bucket)-1]
+			q.buckets[q.current] = bucket[:len(bucket)-1]
+			q.size--
+			return index, q.current, true
+		}
+		q.current++
 	}
-	return false
+	q.current = 0
+	return 0, 0, false
 }
@@ -0,0 +1,87 @@
+package game_controllers
+
+import (
+	"math"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+// SetSeparation makes enemy keep radius tiles away from other enemies, weight says how strongly
+// it's pushed away compared to following the flow field. Enemies with zero weight don't avoid others.
+func (e *Enemy) SetSeparation(radius, weight float64) {
+	e.separationRadius = radius
+	e.separationWeight = weight
+}
+
+// separateEnemies steers enemies away from others standing too close to them, so groups following
+// the same flow field spread around instead of collapsing into a single tile
+func (a *AIAlgorithm) separateEnemies() {
+	for _, enemy := range a.enemies {
+		if enemy.separationWeight <= 0 {
+			continue
+		}
+
+		push := a.separation(enemy)
+		if push == (vec2.T{0, 0}) {
+			continue
+		}
+
+		push.Scale(float32(enemy.separationWeight))
+		direction := vec2.Add(&enemy.direction, &push)
+		if direction.Length() > 1 {
+			direction.Normalize()
+		}
+		// enemies pushed into a wall keep following the flow field, directions have y axis pointing up
+		if a.IsObstacle(Coordinate{
+			X: enemy.position.X + int(math.Round(float64(direction[0]))),
+			Y: enemy.position.Y - int(math.Round(float64(direction[1]))),
+		}) {
+			continue
+		}
+		enemy.direction = direction
+	}
+}
+
+// separation sums pushes away from enemies overlapping with the enemy,
+// the closer the other enemy is the stronger the push
+func (a *AIAlgorithm) separation(enemy *Enemy) vec2.T {
+	push := vec2.T{0, 0}
+	for _, other := range a.enemies {
+		if other == enemy {
+			continue
+		}
+
+		reach := enemy.separationRadius + other.separationRadius
+		// push is added to the direction, so its y axis points up like in flow fields
+		away := vec2.T{
+			float32(enemy.position.X - other.position.X),
+			float32(other.position.Y - enemy.position.Y),
+		}
+		distance := float64(away.Length())
+		if distance >= reach {
+			continue
+		}
+
+		if distance == 0 {
+			away = sidestep(enemy, other)
+		} else {
+			away.Normalize()
+		}
+		away.Scale(float32(1 - distance/reach))
+		push.Add(&away)
+	}
+	return push
+}
+
+// sidestep returns direction that moves enemy out of the tile shared with the other one,
+// both enemies step to the opposite sides of the way they go
+func sidestep(enemy, other *Enemy) vec2.T {
+	side := vec2.T{-enemy.direction[1], enemy.direction[0]}
+	if side == (vec2.T{0, 0}) {
+		side = vec2.T{1, 0}
+	}
+	if enemy.id < other.id {
+		side.Invert()
+	}
+	return *side.Normalize()
+}
@@ -0,0 +1,82 @@
+package game_controllers
+
+import (
+	"testing"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+// separationRoom builds empty room surrounded by walls with given enemies that keep one tile away from others
+func separationRoom(t *testing.T, enemies ...*Enemy) *AIAlgorithm {
+	t.Helper()
+
+	const size = 12
+	collisions := make([]Coordinate, 0)
+	for i := 0; i < size; i++ {
+		collisions = append(collisions,
+			Coordinate{X: i, Y: 0}, Coordinate{X: i, Y: size - 1},
+			Coordinate{X: 0, Y: i}, Coordinate{X: size - 1, Y: i},
+		)
+	}
+
+	a := NewAIAlgorithm()
+	a.SetWidth(size)
+	a.SetHeight(size)
+	a.SetOffset(0, 0)
+	a.SetCollision(collisions)
+	a.InitGraph()
+
+	byId := make(map[uint32]*Enemy)
+	for _, enemy := range enemies {
+		enemy.SetSeparation(1, 1)
+		byId[enemy.id] = enemy
+	}
+	a.SetEnemies(byId)
+	return a
+}
+
+// movesAwayFrom reports whether enemy direction leads away from the tile, directions have y axis pointing up
+func movesAwayFrom(enemy *Enemy, tile Coordinate) bool {
+	away := vec2.T{float32(enemy.position.X - tile.X), float32(tile.Y - enemy.position.Y)}
+	return vec2.Dot(&enemy.direction, &away) > 0
+}
+
+func TestSeparationPushesNeighboursApart(t *testing.T) {
+	tests := []struct {
+		name          string
+		first, second Coordinate
+	}{
+		{"vertical", Coordinate{X: 5, Y: 5}, Coordinate{X: 5, Y: 6}},
+		{"horizontal", Coordinate{X: 5, Y: 5}, Coordinate{X: 6, Y: 5}},
+		{"diagonal", Coordinate{X: 5, Y: 5}, Coordinate{X: 6, Y: 6}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first := NewTestEnemy(1, test.first.X, test.first.Y)
+			second := NewTestEnemy(2, test.second.X, test.second.Y)
+			a := separationRoom(t, first, second)
+			a.separateEnemies()
+
+			if !movesAwayFrom(first, second.position) || !movesAwayFrom(second, first.position) {
+				t.Errorf("enemies at %v and %v got directions %v and %v, they should move apart",
+					first.position, second.position, first.direction, second.direction)
+			}
+		})
+	}
+}
+
+func TestSeparationDoesNotPushIntoWalls(t *testing.T) {
+	// enemy under the top wall is pushed up by the one below it
+	first := NewTestEnemy(1, 5, 1)
+	second := NewTestEnemy(2, 5, 2)
+	a := separationRoom(t, first, second)
+	a.separateEnemies()
+
+	if first.direction != (vec2.T{0, 0}) {
+		t.Errorf("enemy next to the wall got direction %v, it should keep following the flow field", first.direction)
+	}
+	if second.direction[1] >= 0 {
+		t.Errorf("enemy below got direction %v, it should be pushed down", second.direction)
+	}
+}
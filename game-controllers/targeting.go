@@ -0,0 +1,188 @@
+package game_controllers
+
+import "github.com/ungerik/go3d/vec2"
+
+const (
+	// enemy chases the player closest by path
+	TARGET_PROXIMITY = "proximity"
+	// enemy chases the player that dealt it the most threat
+	TARGET_THREAT = "threat"
+	// enemy chases the player that hit it last
+	TARGET_LAST_HIT = "lastHit"
+
+	NO_TARGET uint32 = NO_OWNER
+	// owner of tiles in fields with a single source, it can't be NO_OWNER
+	TARGET_SOURCE uint32 = 0
+	// player has to get this many times more threat than the current target to take enemy over
+	TARGET_SWITCH_THREAT = 1.1
+)
+
+// targetField is distance field of a single player or tile, it's computed only for players
+// that enemies chase while somebody else is closer to them and for tiles where enemies search for players
+type targetField struct {
+	field     *FlowField
+	updatedAt uint64
+}
+
+func (e *Enemy) SetTargeting(targeting string) {
+	e.targeting = targeting
+}
+
+// AddThreat makes enemy remember player that hit it
+func (e *Enemy) AddThreat(playerId uint32, threat float64) {
+	if e.threat == nil {
+		e.threat = make(map[uint32]float64)
+	}
+	e.threat[playerId] += threat
+	e.lastAttacker = playerId
+	e.notice(playerId)
+}
+
+// GetTarget returns id of the player enemy chases
+func (e *Enemy) GetTarget() (uint32, bool) {
+	return e.target, e.target != NO_TARGET
+}
+
+// TargetPosition returns position of the chased player and distance to it in tiles,
+// enemy without target looks at the nearest player
+func (e *Enemy) TargetPosition(players map[uint32]Coordinate) (Coordinate, float64, bool) {
+	target, ok := players[e.target]
+	if !ok {
+		return e.ClosestPlayer(players)
+	}
+	return target, e.distanceTo(target), true
+}
+
+// chooseTarget picks player enemy should chase, closest is the player closest by path
+func (e *Enemy) chooseTarget(closest uint32, players map[uint32]Coordinate) uint32 {
+	switch e.targeting {
+	case TARGET_THREAT:
+		if target, ok := e.highestThreat(players); ok {
+			return target
+		}
+	case TARGET_LAST_HIT:
+		if _, ok := players[e.lastAttacker]; ok {
+			return e.lastAttacker
+		}
+	}
+	return closest
+}
+
+// highestThreat keeps the current target unless somebody else has clearly more threat,
+// so enemies don't jump between players dealing similar damage
+func (e *Enemy) highestThreat(players map[uint32]Coordinate) (uint32, bool) {
+	target, targetThreat := NO_TARGET, 0.0
+	if _, ok := players[e.target]; ok {
+		target, targetThreat = e.target, e.threat[e.target]*TARGET_SWITCH_THREAT
+	}
+	for id, threat := range e.threat {
+		if _, ok := players[id]; ok && threat > targetThreat {
+			target, targetThreat = id, threat
+		}
+	}
+	return target, target != NO_TARGET && e.threat[target] > 0
+}
+
+// updateEnemyDirections points every enemy towards the player it chooses to chase, enemies
+// that can't reach their target chase the closest player and enemies without path to any
+// player keep going where they went before. Enemies with vision go where they saw their target
+// for the last time and wait once they lose track of everybody.
+func (a *AIAlgorithm) updateEnemyDirections() {
+	for _, layer := range a.layers {
+		for id := range layer.targetFields {
+			if _, ok := a.players[id]; !ok {
+				delete(layer.targetFields, id)
+			}
+		}
+	}
+
+	now := a.clock()
+	for _, enemy := range a.enemies {
+		layer := a.layer(enemy.clearance)
+		tile := a.toGrid(enemy.position)
+		known := a.knownPlayers(enemy, now)
+		closest := layer.field.Owner(tile)
+		if _, ok := known[closest]; !ok {
+			closest = enemy.closestPlayerId(known)
+		}
+		enemy.target = enemy.chooseTarget(closest, known)
+		if enemy.target == NO_TARGET && enemy.HasVision() {
+			enemy.direction = vec2.T{0, 0}
+			continue
+		}
+
+		field := a.fieldTowards(layer, enemy, known)
+		if _, ok := field.Distance(tile); !ok && !field.IsBlocked(tile) && enemy.target != closest {
+			enemy.target = closest
+			field = a.fieldTowards(layer, enemy, known)
+		}
+
+		direction, ok := field.Direction(tile)
+		if ok {
+			enemy.direction = direction
+		}
+		// enemy came to the place where it saw the player and the player isn't there anymore
+		if position, ok := known[enemy.target]; ok && position == enemy.position && a.players[enemy.target] != position {
+			enemy.forget(enemy.target)
+		}
+	}
+
+	for _, layer := range a.layers {
+		for tile, search := range layer.searchFields {
+			if search.updatedAt != a.tick {
+				delete(layer.searchFields, tile)
+			}
+		}
+	}
+}
+
+// fieldTowards returns flow field leading enemy to where it thinks its target is
+func (a *AIAlgorithm) fieldTowards(layer *pathLayer, enemy *Enemy, known map[uint32]Coordinate) *FlowField {
+	position, ok := known[enemy.target]
+	switch {
+	case !ok:
+		return layer.field
+	case position != a.players[enemy.target]:
+		return a.searchField(layer, a.toGrid(position))
+	case enemy.target == layer.field.Owner(a.toGrid(enemy.position)):
+		return layer.field
+	default:
+		return a.targetField(layer, enemy.target)
+	}
+}
+
+// targetField returns distance field of the player repaired for the current positions
+func (a *AIAlgorithm) targetField(layer *pathLayer, playerId uint32) *FlowField {
+	target, ok := layer.targetFields[playerId]
+	if !ok {
+		target = &targetField{field: layer.field.NewLayer()}
+		layer.targetFields[playerId] = target
+	}
+	a.updateTargetField(target, a.sources[playerId])
+	return target.field
+}
+
+// searchField returns distance field of the tile where some player was seen for the last time,
+// fields of tiles nobody goes to are dropped at the end of the update
+func (a *AIAlgorithm) searchField(layer *pathLayer, tile Coordinate) *FlowField {
+	search, ok := layer.searchFields[tile]
+	if !ok {
+		search = &targetField{field: layer.field.NewLayer()}
+		layer.searchFields[tile] = search
+	}
+	a.updateTargetField(search, tile)
+	return search.field
+}
+
+// updateTargetField repairs single source field once per update
+func (a *AIAlgorithm) updateTargetField(target *targetField, source Coordinate) {
+	if target.updatedAt == a.tick {
+		return
+	}
+
+	target.updatedAt = a.tick
+	target.field.SetBounds(a.searchMin, a.searchMax)
+	clear(a.targetSource)
+	a.targetSource[TARGET_SOURCE] = source
+	target.field.Update(a.targetSource)
+}
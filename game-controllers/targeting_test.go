@@ -0,0 +1,81 @@
+package game_controllers
+
+import "testing"
+
+func TestChooseTarget(t *testing.T) {
+	players := map[uint32]Coordinate{1: {X: 2, Y: 2}, 2: {X: 8, Y: 8}, 3: {X: 4, Y: 4}}
+
+	tests := []struct {
+		name      string
+		targeting string
+		threat    map[uint32]float64
+		// players that hit enemy in order
+		hits     []uint32
+		current  uint32
+		expected uint32
+	}{
+		{"proximity", TARGET_PROXIMITY, nil, []uint32{2}, NO_TARGET, 1},
+		{"threat", TARGET_THREAT, map[uint32]float64{2: 10, 3: 5}, nil, NO_TARGET, 2},
+		{"threat without hits", TARGET_THREAT, nil, nil, NO_TARGET, 1},
+		{"threat of player that left", TARGET_THREAT, map[uint32]float64{9: 50, 3: 5}, nil, NO_TARGET, 3},
+		// current target is kept until somebody has clearly more threat
+		{"similar threat keeps target", TARGET_THREAT, map[uint32]float64{2: 10.5, 3: 10}, nil, 3, 3},
+		{"clearly more threat switches", TARGET_THREAT, map[uint32]float64{2: 12, 3: 10}, nil, 3, 2},
+		{"last hit", TARGET_LAST_HIT, nil, []uint32{2, 3}, NO_TARGET, 3},
+		{"last hit by player that left", TARGET_LAST_HIT, nil, []uint32{2, 9}, NO_TARGET, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enemy := NewTestEnemy(100, 0, 0)
+			enemy.SetTargeting(test.targeting)
+			for _, id := range test.hits {
+				enemy.AddThreat(id, 0)
+			}
+			for id, threat := range test.threat {
+				enemy.AddThreat(id, threat)
+			}
+			enemy.target = test.current
+
+			if target := enemy.chooseTarget(1, players); target != test.expected {
+				t.Errorf("enemy chose player %d, expected %d", target, test.expected)
+			}
+		})
+	}
+}
+
+func TestEnemiesChaseChosenTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		targeting string
+		hits      []uint32
+		target    uint32
+		// sign of x direction, player 1 stands left and player 2 right of the enemy
+		side float32
+	}{
+		{"closest player", TARGET_PROXIMITY, []uint32{2}, 1, -1},
+		{"player with threat", TARGET_THREAT, []uint32{2}, 2, 1},
+		{"last attacker", TARGET_LAST_HIT, []uint32{1, 2}, 2, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := walledRoom(t, 20)
+			enemy := NewTestEnemy(100, 9, 10)
+			enemy.SetTargeting(test.targeting)
+			for _, id := range test.hits {
+				enemy.AddThreat(id, 10)
+			}
+			a.SetEnemies(map[uint32]*Enemy{100: enemy})
+			a.SetPlayers(map[uint32]Coordinate{1: {X: 5, Y: 10}, 2: {X: 17, Y: 10}})
+			a.CreateDistancesMap()
+
+			if target, ok := enemy.GetTarget(); !ok || target != test.target {
+				t.Errorf("enemy chases player %d, expected %d", target, test.target)
+			}
+			if enemy.direction[0]*test.side <= 0 {
+				t.Errorf("enemy goes in direction %v, its target is on the other side", enemy.direction)
+			}
+		})
+	}
+}
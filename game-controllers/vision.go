@@ -0,0 +1,103 @@
+package game_controllers
+
+import (
+	"math"
+	"time"
+)
+
+// sighting is the last place where enemy saw the player
+type sighting struct {
+	position Coordinate
+	forgetAt time.Time
+}
+
+// SetVision makes enemy see players only visionRange tiles around itself and only when no wall stands
+// between them, players out of sight are remembered where they were seen for memory seconds.
+// Enemies without vision always know where every player is.
+func (e *Enemy) SetVision(visionRange, memory float64) {
+	e.visionRange = visionRange
+	e.memory = time.Duration(memory * float64(time.Second))
+	e.sightings = make(map[uint32]sighting)
+	e.noticed = make(map[uint32]bool)
+}
+
+func (e *Enemy) HasVision() bool {
+	return e.visionRange > 0
+}
+
+// LastKnownPosition returns where enemy saw the player for the last time, enemies
+// without vision know positions of all players
+func (e *Enemy) LastKnownPosition(playerId uint32) (Coordinate, bool) {
+	sighting, ok := e.sightings[playerId]
+	return sighting.position, ok
+}
+
+// notice makes enemy find out where the player is next time it looks around, even without seeing it
+func (e *Enemy) notice(playerId uint32) {
+	if e.HasVision() {
+		e.noticed[playerId] = true
+	}
+}
+
+func (e *Enemy) forget(playerId uint32) {
+	delete(e.sightings, playerId)
+}
+
+// closestPlayerId returns id of the player nearest in straight line
+func (e *Enemy) closestPlayerId(players map[uint32]Coordinate) uint32 {
+	closest := NO_TARGET
+	closestDistance := math.MaxFloat64
+	for id, player := range players {
+		distance := e.distanceTo(player)
+		if distance < closestDistance || distance == closestDistance && id < closest {
+			closest = id
+			closestDistance = distance
+		}
+	}
+	return closest
+}
+
+// HasLineOfSight reports whether no wall stands between two tiles given in map coordinates
+func (a *AIAlgorithm) HasLineOfSight(from, to Coordinate) bool {
+	return !a.CrossesObstacle(from, to)
+}
+
+// CanSee reports whether enemy sees tile given in map coordinates
+func (a *AIAlgorithm) CanSee(enemy *Enemy, tile Coordinate) bool {
+	if !enemy.HasVision() {
+		return true
+	}
+	return enemy.distanceTo(tile) <= enemy.visionRange && a.HasLineOfSight(enemy.position, tile)
+}
+
+// knownPlayers returns positions of players enemy knows about, players out of sight
+// are at positions where enemy saw them for the last time
+func (a *AIAlgorithm) knownPlayers(enemy *Enemy, now time.Time) map[uint32]Coordinate {
+	if !enemy.HasVision() {
+		return a.players
+	}
+
+	a.look(enemy, now)
+	clear(a.known)
+	for id, sighting := range enemy.sightings {
+		a.known[id] = sighting.position
+	}
+	return a.known
+}
+
+// look remembers players enemy sees or noticed since the last look
+// and forgets players it didn't see for too long
+func (a *AIAlgorithm) look(enemy *Enemy, now time.Time) {
+	for id, player := range a.players {
+		if enemy.noticed[id] || a.CanSee(enemy, player) {
+			enemy.sightings[id] = sighting{position: player, forgetAt: now.Add(enemy.memory)}
+		}
+	}
+	clear(enemy.noticed)
+
+	for id, sighting := range enemy.sightings {
+		if _, ok := a.players[id]; !ok || now.After(sighting.forgetAt) {
+			delete(enemy.sightings, id)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package game_controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ungerik/go3d/vec2"
+)
+
+func TestCanSee(t *testing.T) {
+	a := walledRoom(t, 20, Coordinate{X: 10, Y: 8})
+
+	tests := []struct {
+		name     string
+		vision   float64
+		tile     Coordinate
+		expected bool
+	}{
+		{"in range", 5, Coordinate{X: 10, Y: 14}, true},
+		{"out of range", 5, Coordinate{X: 10, Y: 16}, false},
+		{"behind wall", 10, Coordinate{X: 10, Y: 6}, false},
+		{"past the wall corner", 10, Coordinate{X: 13, Y: 6}, true},
+		{"without vision", 0, Coordinate{X: 10, Y: 2}, true},
+	}
+
+	for _, test := range tests {
+		enemy := NewTestEnemy(100, 10, 10)
+		if test.vision > 0 {
+			enemy.SetVision(test.vision, 1)
+		}
+		if seen := a.CanSee(enemy, test.tile); seen != test.expected {
+			t.Errorf("%s: enemy sees %v: %v, expected %v", test.name, test.tile, seen, test.expected)
+		}
+	}
+}
+
+func TestEnemyRemembersPlayersOutOfSight(t *testing.T) {
+	now := time.Now()
+	a := walledRoom(t, 20, Coordinate{X: 10, Y: 8}, Coordinate{X: 11, Y: 8}, Coordinate{X: 9, Y: 8})
+	a.clock = func() time.Time { return now }
+
+	enemy := NewTestEnemy(100, 10, 12)
+	enemy.SetVision(8, 2)
+	a.SetEnemies(map[uint32]*Enemy{100: enemy})
+
+	steps := []struct {
+		name   string
+		after  time.Duration
+		player Coordinate
+		known  bool
+		seenAt Coordinate
+	}{
+		{"seen", 0, Coordinate{X: 10, Y: 10}, true, Coordinate{X: 10, Y: 10}},
+		{"hidden behind wall", time.Second, Coordinate{X: 10, Y: 6}, true, Coordinate{X: 10, Y: 10}},
+		{"forgotten after memory", 2500 * time.Millisecond, Coordinate{X: 10, Y: 6}, false, Coordinate{}},
+		{"seen again", 0, Coordinate{X: 14, Y: 12}, true, Coordinate{X: 14, Y: 12}},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.after)
+		a.SetPlayers(map[uint32]Coordinate{1: step.player})
+		a.CreateDistancesMap()
+
+		position, known := enemy.LastKnownPosition(1)
+		if known != step.known || position != step.seenAt {
+			t.Fatalf("%s: enemy knows player at %v: %v, expected %v: %v", step.name, position, known, step.seenAt, step.known)
+		}
+		if _, chasing := enemy.GetTarget(); chasing != step.known {
+			t.Errorf("%s: enemy chases player: %v", step.name, chasing)
+		}
+		if !step.known && enemy.direction != (vec2.T{0, 0}) {
+			t.Errorf("%s: enemy that lost track of everybody moves with direction %v", step.name, enemy.direction)
+		}
+	}
+}
+
+func TestHitMakesEnemyNoticePlayer(t *testing.T) {
+	a := walledRoom(t, 20, Coordinate{X: 9, Y: 8}, Coordinate{X: 10, Y: 8}, Coordinate{X: 11, Y: 8})
+	enemy := NewTestEnemy(100, 10, 12)
+	enemy.SetVision(8, 2)
+	a.SetEnemies(map[uint32]*Enemy{100: enemy})
+	a.SetPlayers(map[uint32]Coordinate{1: {X: 10, Y: 5}})
+
+	a.CreateDistancesMap()
+	if _, known := enemy.LastKnownPosition(1); known {
+		t.Fatalf("enemy knows about player hidden behind wall")
+	}
+
+	enemy.AddThreat(1, 5)
+	a.CreateDistancesMap()
+	if position, known := enemy.LastKnownPosition(1); !known || position != (Coordinate{X: 10, Y: 5}) {
+		t.Errorf("enemy hit by hidden player knows about it at %v: %v", position, known)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type adminCommand struct {
+	usage string
+	run   func(args []string, out io.Writer)
+}
+
+var adminCommands = map[string]adminCommand{
+	"offenders": {
+		usage: "offenders - list players flagged for suspicious movement",
+		run:   printOffenders,
+	},
+	"mute": {
+		usage: "mute <player id> - stop passing chat messages of player, mute stays when player reconnects",
+		run: func(args []string, out io.Writer) {
+			setPlayerMuted(args, out, true)
+		},
+	},
+	"unmute": {
+		usage: "unmute <player id> - let player use chat again",
+		run: func(args []string, out io.Writer) {
+			setPlayerMuted(args, out, false)
+		},
+	},
+	"kick": {
+		usage: "kick <player id> [reason] - disconnect player",
+		run:   kickPlayer,
+	},
+	"ban": {
+		usage: "ban <player id> [reason] - ban address of connected player and disconnect it",
+		run:   banPlayer,
+	},
+	"banip": {
+		usage: "banip <ip> [reason] - ban ip address",
+		run:   banIP,
+	},
+	"banname": {
+		usage: "banname <name> [reason] - ban player name, names with spaces go in double quotes",
+		run:   banName,
+	},
+	"unban": {
+		usage: "unban <ip or name> - remove ban, names with spaces go in double quotes",
+		run:   unban,
+	},
+	"bans": {
+		usage: "bans - list banned ip addresses and names",
+		run:   printBans,
+	},
+	"save": {
+		usage: "save [file] - save current run, file from config is used by default",
+		run:   saveRunCommand,
+	},
+	"flowfield": {
+		usage: "flowfield [directory] - export enemy flow fields of the next map update as JSON and PNG",
+		run:   flowFieldCommand,
+	},
+	"say": {
+		usage: "say <message> - send system message to every player",
+		run:   broadcastSystemMessage,
+	},
+}
+
+// runAdminConsole executes commands read line by line from in until it is closed
+func runAdminConsole(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "help" {
+			printAdminHelp(out)
+			continue
+		}
+
+		command, ok := adminCommands[fields[0]]
+		if !ok {
+			fmt.Fprintf(out, "unknown command: %s, type help to list commands\n", fields[0])
+			continue
+		}
+		command.run(fields[1:], out)
+	}
+}
+
+func printAdminHelp(out io.Writer) {
+	names := make([]string, 0, len(adminCommands))
+	for name := range adminCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fmt.Fprintln(out, adminCommands[name].usage)
+	}
+}
+
+func printOffenders(_ []string, out io.Writer) {
+	offenders := validator.offenders()
+	if len(offenders) == 0 {
+		fmt.Fprintln(out, "no offenders")
+		return
+	}
+
+	for id, violations := range offenders {
+		fmt.Fprintf(out, "player %d: %d suspicious movements\n", id, violations)
+	}
+}
+
+// parsePlayerID reads player id from the first argument of command
+func parsePlayerID(args []string, out io.Writer) (uint32, bool) {
+	if len(args) == 0 {
+		fmt.Fprintln(out, "missing player id")
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		fmt.Fprintf(out, "invalid player id: %s\n", args[0])
+		return 0, false
+	}
+	return uint32(id), true
+}
+
+func setPlayerMuted(args []string, out io.Writer, muted bool) {
+	id, ok := parsePlayerID(args, out)
+	if !ok {
+		return
+	}
+
+	if !chat.setMuted(id, muted) {
+		fmt.Fprintf(out, "player %d is not connected\n", id)
+		return
+	}
+	if muted {
+		fmt.Fprintf(out, "player %d muted\n", id)
+	} else {
+		fmt.Fprintf(out, "player %d unmuted\n", id)
+	}
+}
+
+func broadcastSystemMessage(args []string, out io.Writer) {
+	if len(args) == 0 {
+		fmt.Fprintln(out, "missing message")
+		return
+	}
+
+	encoded := systemMessage(strings.Join(args, " "))
+
+	connLock.RLock()
+	for _, conn := range tcpConns {
+		conn.Write(encoded)
+	}
+	connLock.RUnlock()
+}
+
+// reasonFromArgs joins arguments following the first one, default reason is used when there are none
+func reasonFromArgs(args []string, defaultReason string) string {
+	if len(args) < 2 {
+		return defaultReason
+	}
+	return strings.Join(args[1:], " ")
+}
+
+func kickPlayer(args []string, out io.Writer) {
+	id, ok := parsePlayerID(args, out)
+	if !ok {
+		return
+	}
+
+	connLock.RLock()
+	_, connected := tcpConns[id]
+	connLock.RUnlock()
+	if !connected {
+		fmt.Fprintf(out, "player %d is not connected\n", id)
+		return
+	}
+
+	kicks.request(id, reasonFromArgs(args, "Kicked by admin"))
+	fmt.Fprintf(out, "player %d kicked\n", id)
+}
+
+func banPlayer(args []string, out io.Writer) {
+	id, ok := parsePlayerID(args, out)
+	if !ok {
+		return
+	}
+
+	connLock.RLock()
+	conn, connected := tcpConns[id]
+	connLock.RUnlock()
+	if !connected {
+		fmt.Fprintf(out, "player %d is not connected\n", id)
+		return
+	}
+
+	reason := reasonFromArgs(args, "Banned by admin")
+	ip := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	if err := bans.banIP(ip, reason); err != nil {
+		fmt.Fprintf(out, "couldn't save ban list: %v\n", err)
+	}
+
+	kicks.request(id, reason)
+	fmt.Fprintf(out, "player %d banned, address: %s\n", id, ip)
+}
+
+func banIP(args []string, out io.Writer) {
+	if len(args) == 0 || net.ParseIP(args[0]) == nil {
+		fmt.Fprintln(out, "missing or invalid ip address")
+		return
+	}
+
+	if err := bans.banIP(args[0], reasonFromArgs(args, "Banned by admin")); err != nil {
+		fmt.Fprintf(out, "couldn't save ban list: %v\n", err)
+	}
+	fmt.Fprintf(out, "address %s banned\n", args[0])
+}
+
+func banName(args []string, out io.Writer) {
+	name, rest, ok := nameFromArgs(args)
+	if !ok {
+		fmt.Fprintln(out, "missing name")
+		return
+	}
+
+	reason := "Banned by admin"
+	if len(rest) > 0 {
+		reason = strings.Join(rest, " ")
+	}
+	if err := bans.banName(name, reason); err != nil {
+		fmt.Fprintf(out, "couldn't save ban list: %v\n", err)
+	}
+
+	connLock.RLock()
+	gameLock.Lock()
+	ids := game.playersNamed(name)
+	gameLock.Unlock()
+	kicked := 0
+	for _, id := range ids {
+		if _, connected := tcpConns[id]; connected {
+			kicks.request(id, reason)
+			kicked++
+		}
+	}
+	connLock.RUnlock()
+	fmt.Fprintf(out, "name %s banned, %d players disconnected\n", name, kicked)
+}
+
+func unban(args []string, out io.Writer) {
+	ipOrName, _, ok := nameFromArgs(args)
+	if !ok {
+		fmt.Fprintln(out, "missing ip address or name")
+		return
+	}
+
+	removed, err := bans.unban(ipOrName)
+	if err != nil {
+		fmt.Fprintf(out, "couldn't save ban list: %v\n", err)
+	}
+	if !removed {
+		fmt.Fprintf(out, "%s is not banned\n", ipOrName)
+		return
+	}
+	fmt.Fprintf(out, "%s unbanned\n", ipOrName)
+}
+
+// nameFromArgs reads player name from the first argument of command, name with spaces like "Player 3"
+// has to be put in double quotes. It returns arguments that follow the name.
+func nameFromArgs(args []string) (string, []string, bool) {
+	if len(args) == 0 {
+		return "", nil, false
+	}
+	if !strings.HasPrefix(args[0], `"`) {
+		return args[0], args[1:], true
+	}
+
+	for i, arg := range args {
+		if (i > 0 || len(arg) > 1) && strings.HasSuffix(arg, `"`) {
+			name := strings.Join(args[:i+1], " ")
+			name = name[1 : len(name)-1]
+			return name, args[i+1:], name != ""
+		}
+	}
+	return "", nil, false
+}
+
+func printBans(_ []string, out io.Writer) {
+	entries := bans.list()
+	if len(entries.IPs) == 0 && len(entries.Names) == 0 {
+		fmt.Fprintln(out, "no bans")
+		return
+	}
+
+	for ip, reason := range entries.IPs {
+		fmt.Fprintf(out, "ip %s: %s\n", ip, reason)
+	}
+	for name, reason := range entries.Names {
+		fmt.Fprintf(out, "name %s: %s\n", name, reason)
+	}
+}
+
+func saveRunCommand(args []string, out io.Writer) {
+	path := config.SaveFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if err := saveRun(path); err != nil {
+		fmt.Fprintf(out, "couldn't save run: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "run saved to %s\n", path)
+}
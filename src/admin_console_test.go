@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// connectTestPlayer registers player in the game as if it joined over loopback connection
+func connectTestPlayer(t *testing.T, name string) uint32 {
+	t.Helper()
+
+	profile := defaultProfile()
+	profile.name = name
+	id := game.createInitialInfo(profile).Player.Id
+	tcpConns[id] = tcpPair(t)
+	kicks.connect(id)
+	return id
+}
+
+func TestBanNameKicksConnectedPlayers(t *testing.T) {
+	game = newGame()
+	tcpConns = make(map[uint32]*net.TCPConn)
+	kicks = newKickQueue()
+	bans = newBanList(filepath.Join(t.TempDir(), "bans.json"))
+
+	alice := connectTestPlayer(t, "Alice")
+	bob := connectTestPlayer(t, "Bob")
+
+	out := strings.Builder{}
+	banName([]string{"alice", "spamming"}, &out)
+
+	if reason, ok := kicks.take(alice); !ok || reason != "spamming" {
+		t.Errorf("banned player wasn't kicked, kick: %q %v", reason, ok)
+	}
+	if _, ok := kicks.take(bob); ok {
+		t.Errorf("player with another name was kicked")
+	}
+	if _, banned := bans.check("10.0.0.1", "ALICE"); !banned {
+		t.Errorf("name wasn't added to ban list")
+	}
+	if !strings.Contains(out.String(), "1 players disconnected") {
+		t.Errorf("admin was told %q", out.String())
+	}
+}
+
+func TestNameFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		name string
+		rest []string
+		ok   bool
+	}{
+		{nil, "", nil, false},
+		{[]string{"Alice"}, "Alice", []string{}, true},
+		{[]string{"Alice", "too", "loud"}, "Alice", []string{"too", "loud"}, true},
+		{[]string{`"Player`, `3"`, "spam"}, "Player 3", []string{"spam"}, true},
+		{[]string{`"Player3"`}, "Player3", []string{}, true},
+	}
+
+	for _, test := range tests {
+		name, rest, ok := nameFromArgs(test.args)
+		if name != test.name || ok != test.ok || strings.Join(rest, " ") != strings.Join(test.rest, " ") {
+			t.Errorf("nameFromArgs(%q) = %q %q %v, expected %q %q %v", test.args, name, rest, ok, test.name, test.rest, test.ok)
+		}
+	}
+}
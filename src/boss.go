@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	g "server/game-controllers"
+	u "server/utils"
+)
+
+var (
+	bosses         = make(map[uint32]*g.BossController)
+	lastBossUpdate = time.Now()
+)
+
+var bossStageNames = map[g.BossStage]string{
+	g.BOSS_TELEGRAPH: "telegraph",
+	g.BOSS_ATTACKING: "attack",
+}
+
+// bossConfig returns config of the first enemy defined as a boss
+func bossConfig() (u.EnemyData, bool) {
+	for _, enemyConfig := range config.EnemyData {
+		if enemyConfig.Boss != nil {
+			return enemyConfig, true
+		}
+	}
+	return u.EnemyData{}, false
+}
+
+// minionConfig returns config used for i-th enemy spawned in regular room, kinds of enemies take turns
+func minionConfig(i int) u.EnemyData {
+	minions := make([]u.EnemyData, 0, len(config.EnemyData))
+	for _, enemyConfig := range config.EnemyData {
+		if enemyConfig.Boss == nil {
+			minions = append(minions, enemyConfig)
+		}
+	}
+	if len(minions) == 0 {
+		return config.EnemyData[0]
+	}
+	return minions[i%len(minions)]
+}
+
+// updateBosses advances every boss in the current room, creating controllers for bosses that don't have one yet.
+// Minions summoned by bosses are added to the room right away and players caught by slams are returned as hits.
+// enemiesLock has to be locked by the caller.
+func updateBosses() ([]*pb.StateUpdate, []*g.Enemy, []playerHit) {
+	now := time.Now()
+	dt := now.Sub(lastBossUpdate).Seconds()
+	lastBossUpdate = now
+
+	updates := make([]*pb.StateUpdate, 0)
+	summoned := make([]*g.Enemy, 0)
+	hits := make([]playerHit, 0)
+
+	for id, enemy := range enemies {
+		enemyConfig, ok := enemyConfigByName(enemy.GetName())
+		if !ok || enemyConfig.Boss == nil {
+			continue
+		}
+
+		boss, ok := bosses[id]
+		if !ok {
+			boss = g.NewBossController(enemy, enemyConfig.HP, *enemyConfig.Boss)
+			bosses[id] = boss
+			updates = append(updates, bossStateUpdate(boss))
+		}
+
+		for _, event := range boss.Update(dt, players) {
+			updates = append(updates, bossAttackUpdate(enemy, event))
+			if event.Stage != g.BOSS_ATTACKING {
+				continue
+			}
+			switch event.Data.Type {
+			case g.SUMMON_ATTACK:
+				summoned = append(summoned, summonMinions(enemy, event.Data)...)
+			case g.SLAM_ATTACK:
+				hits = append(hits, slamHits(enemy, event)...)
+			}
+		}
+	}
+
+	return updates, summoned, hits
+}
+
+// slamHits finds players standing within radius of the place the slam lands on,
+// enemiesLock has to be locked by the caller
+func slamHits(boss *g.Enemy, event g.BossEvent) []playerHit {
+	hits := make([]playerHit, 0)
+	targetX := float64(event.Target.X * SCALLING_FACTOR)
+	targetY := float64(event.Target.Y * SCALLING_FACTOR)
+	radius := event.Data.Radius * SCALLING_FACTOR
+
+	for playerId, tile := range players {
+		x, y := validator.trustedPosition(playerId, float32(tile.X*SCALLING_FACTOR), float32(tile.Y*SCALLING_FACTOR))
+		if math.Hypot(float64(x)-targetX, float64(y)-targetY) <= radius {
+			hits = append(hits, playerHit{playerId: playerId, enemyId: boss.GetId(), damage: event.Data.Damage})
+		}
+	}
+	return hits
+}
+
+// summonMinions spawns minions evenly around the boss, enemiesLock has to be locked by the caller
+func summonMinions(boss *g.Enemy, attack u.BossAttackData) []*g.Enemy {
+	minionConfig, ok := enemyConfigByName(attack.SummonName)
+	if !ok {
+		logger.Info("Boss summons enemy that isn't defined in config", "name", attack.SummonName)
+		return nil
+	}
+
+	summoned := make([]*g.Enemy, 0, attack.SummonCount)
+	for i := 0; i < attack.SummonCount; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(attack.SummonCount)
+		x := boss.GetPosition().X + int(math.Round(attack.Radius*math.Cos(angle)))
+		y := boss.GetPosition().Y + int(math.Round(attack.Radius*math.Sin(angle)))
+
+		id := enemyIds.getID()
+		enemies[id] = newEnemyFromConfig(id, x, y, minionConfig)
+		summoned = append(summoned, enemies[id])
+	}
+	return summoned
+}
+
+// handleBossHit updates phase of boss after it got hit and tells players about its health,
+// enemiesLock has to be locked by the caller
+func handleBossHit(enemy *g.Enemy, killed bool) *pb.StateUpdate {
+	boss, ok := bosses[enemy.GetId()]
+	if !ok {
+		return nil
+	}
+
+	if killed {
+		delete(bosses, enemy.GetId())
+	} else if boss.UpdatePhase() {
+		logger.Info("Boss entered new phase", "enemyId", enemy.GetId(), "phase", boss.GetPhase())
+	}
+	return bossStateUpdate(boss)
+}
+
+// bossStateUpdate describes boss health bar
+func bossStateUpdate(boss *g.BossController) *pb.StateUpdate {
+	enemy := boss.GetEnemy()
+	msg := &pb.StateUpdate{
+		Variant: BOSS_STATE,
+		EnemySpawnerPositions: []*pb.Enemy{{
+			Id:   enemy.GetId(),
+			Name: enemy.GetName(),
+			Hp:   enemy.GetHp(),
+		}},
+	}
+	setExtFloat(msg, EXT_BOSS_MAX_HP, float32(boss.GetMaxHp()))
+	setExtUint(msg, EXT_BOSS_PHASE, uint64(boss.GetPhase()))
+	return msg
+}
+
+func bossAttackUpdate(enemy *g.Enemy, event g.BossEvent) *pb.StateUpdate {
+	msg := &pb.StateUpdate{
+		Variant: BOSS_ATTACK,
+		EnemySpawnerPositions: []*pb.Enemy{{
+			Id:        enemy.GetId(),
+			PositionX: float32(enemy.GetPosition().X) * SCALLING_FACTOR,
+			PositionY: float32(enemy.GetPosition().Y) * SCALLING_FACTOR,
+		}},
+	}
+	setExtString(msg, EXT_BOSS_ATTACK, event.Attack)
+	setExtString(msg, EXT_BOSS_ATTACK_STAGE, bossStageNames[event.Stage])
+	setExtFloat(msg, EXT_BOSS_TARGET_X, float32(event.Target.X)*SCALLING_FACTOR)
+	setExtFloat(msg, EXT_BOSS_TARGET_Y, float32(event.Target.Y)*SCALLING_FACTOR)
+	setExtFloat(msg, EXT_BOSS_ATTACK_RADIUS, float32(event.Data.Radius)*SCALLING_FACTOR)
+	setExtFloat(msg, EXT_BOSS_ATTACK_DAMAGE, float32(event.Data.Damage))
+	if event.Data.Type == g.CHARGE_ATTACK {
+		setExtFloat(msg, EXT_BOSS_ATTACK_SPEED, float32(event.Data.Speed))
+	}
+	return msg
+}
+
+// broadcastBossUpdates sends boss updates and summoned minions to every player
+func broadcastBossUpdates(updates []*pb.StateUpdate, summoned []*g.Enemy) {
+	if len(updates) == 0 && len(summoned) == 0 {
+		return
+	}
+
+	connLock.RLock()
+	defer connLock.RUnlock()
+
+	for _, update := range updates {
+		broadcastUpdate(update)
+	}
+	if len(summoned) > 0 {
+		sendEnemies(summoned)
+	}
+}
+
+// clearBosses forgets controllers of all bosses, enemiesLock has to be locked by the caller
+func clearBosses() {
+	bosses = make(map[uint32]*g.BossController)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"google.golang.org/protobuf/proto"
+	u "server/utils"
+)
+
+// SERVER_PLAYER_ID is used as sender of system messages, it never belongs to a real player
+const SERVER_PLAYER_ID = 0
+
+// chatModerator keeps mutes by ip address and name chosen by the muted player, so reconnecting
+// doesn't lift the mute. Mutes last until the server stops or the player is unmuted.
+type chatModerator struct {
+	lock       sync.Mutex
+	players    map[uint32]chatIdentity
+	mutedIPs   map[string]bool
+	mutedNames map[string]bool
+	filter     *regexp.Regexp
+}
+
+type chatIdentity struct {
+	ip, name string
+}
+
+func newChatModerator() *chatModerator {
+	return &chatModerator{
+		players:    make(map[uint32]chatIdentity),
+		mutedIPs:   make(map[string]bool),
+		mutedNames: make(map[string]bool),
+	}
+}
+
+// loadFilter builds filter from words listed in file, missing file leaves chat unfiltered
+func (c *chatModerator) loadFilter(filePath string) {
+	if filePath == "" {
+		return
+	}
+
+	words, err := u.NewWordListParser().ParseWordList(filePath)
+	if err != nil {
+		logger.Info("Couldn't load chat filter", "file", filePath, "error", err)
+		return
+	}
+	if len(words) == 0 {
+		return
+	}
+
+	for i := range words {
+		words[i] = regexp.QuoteMeta(words[i])
+	}
+
+	c.lock.Lock()
+	c.filter = regexp.MustCompile(`(?i)\b(` + strings.Join(words, "|") + `)\b`)
+	c.lock.Unlock()
+}
+
+func (c *chatModerator) censor(text string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.filter == nil {
+		return text
+	}
+	return c.filter.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	})
+}
+
+// addPlayer remembers address and chosen name of connected player, empty name means the player
+// got default name from the server. Player muted before keeps the mute.
+func (c *chatModerator) addPlayer(playerID uint32, ip, name string) {
+	c.lock.Lock()
+	c.players[playerID] = chatIdentity{ip: ip, name: strings.ToLower(name)}
+	c.lock.Unlock()
+}
+
+// setMuted mutes or unmutes address and name of connected player, it returns false for unknown players
+func (c *chatModerator) setMuted(playerID uint32, muted bool) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	identity, ok := c.players[playerID]
+	if !ok {
+		return false
+	}
+	if muted {
+		c.mutedIPs[identity.ip] = true
+		if identity.name != "" {
+			c.mutedNames[identity.name] = true
+		}
+	} else {
+		delete(c.mutedIPs, identity.ip)
+		delete(c.mutedNames, identity.name)
+	}
+	return true
+}
+
+func (c *chatModerator) isMuted(playerID uint32) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	identity, ok := c.players[playerID]
+	return ok && (c.mutedIPs[identity.ip] || identity.name != "" && c.mutedNames[identity.name])
+}
+
+// removePlayer forgets disconnected player, its mute stays
+func (c *chatModerator) removePlayer(playerID uint32) {
+	c.lock.Lock()
+	delete(c.players, playerID)
+	c.lock.Unlock()
+}
+
+// handleChatMessage moderates chat message or emote sent by player and passes it to everybody,
+// connLock has to be read locked by the caller
+func handleChatMessage(update *pb.StateUpdate, id uint32, conn *net.TCPConn) {
+	if chat.isMuted(id) {
+		conn.Write(systemMessage("You are muted"))
+		return
+	}
+
+	responseMsg := &pb.StateUpdate{
+		Player:  &pb.Player{Id: id},
+		Variant: CHAT_MESSAGE,
+	}
+
+	if emote, ok := getExtString(update, EXT_CHAT_EMOTE); ok {
+		if !slices.Contains(config.ChatEmotes, emote) {
+			conn.Write(systemMessage(fmt.Sprintf("Unknown emote: %s", emote)))
+			return
+		}
+		setExtString(responseMsg, EXT_CHAT_EMOTE, emote)
+	} else {
+		text, _ := getExtString(update, EXT_CHAT_TEXT)
+		text = stripControlCharacters(text)
+
+		if text == "" {
+			return
+		}
+		if utf8.RuneCountInString(text) > config.MaxChatMessageLength {
+			conn.Write(systemMessage(fmt.Sprintf("Message is longer than %d characters", config.MaxChatMessageLength)))
+			return
+		}
+		setExtString(responseMsg, EXT_CHAT_TEXT, chat.censor(text))
+	}
+
+	serializedMsg, err := proto.Marshal(responseMsg)
+	if err != nil {
+		logger.Info("Failed to serialize chat message", "error", err)
+		return
+	}
+
+	encoded := addPrefixAndPadding(serializedMsg)
+	for _, otherConn := range tcpConns {
+		otherConn.Write(encoded)
+	}
+}
+
+// stripControlCharacters removes control characters and surrounding whitespace from text
+func stripControlCharacters(text string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text))
+}
+
+// systemMessage creates encoded chat message sent in the name of the server
+func systemMessage(text string) []byte {
+	msg := &pb.StateUpdate{
+		Player:  &pb.Player{Id: SERVER_PLAYER_ID},
+		Variant: CHAT_MESSAGE,
+	}
+	setExtString(msg, EXT_CHAT_TEXT, text)
+
+	serializedMsg, err := proto.Marshal(msg)
+	if err != nil {
+		logger.Info("Failed to serialize system message", "error", err)
+	}
+
+	return addPrefixAndPadding(serializedMsg)
+}
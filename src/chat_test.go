@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestMutesSurviveReconnect(t *testing.T) {
+	type player struct {
+		id       uint32
+		ip, name string
+	}
+	tests := []struct {
+		name      string
+		muted     player
+		reconnect player
+		expected  bool
+	}{
+		{"same address and name", player{1, "10.0.0.1", "Alice"}, player{2, "10.0.0.1", "Alice"}, true},
+		{"name from another address", player{1, "10.0.0.1", "Alice"}, player{2, "10.0.0.2", "alice"}, true},
+		{"address under another name", player{1, "10.0.0.1", "Alice"}, player{2, "10.0.0.1", "Bob"}, true},
+		{"unnamed from the same address", player{1, "10.0.0.1", ""}, player{3, "10.0.0.1", ""}, true},
+		{"unnamed getting the same id", player{1, "10.0.0.1", ""}, player{1, "10.0.0.2", ""}, false},
+		{"somebody else", player{1, "10.0.0.1", "Alice"}, player{2, "10.0.0.2", "Bob"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newChatModerator()
+			c.addPlayer(test.muted.id, test.muted.ip, test.muted.name)
+			if !c.setMuted(test.muted.id, true) {
+				t.Fatalf("connected player couldn't be muted")
+			}
+			c.removePlayer(test.muted.id)
+
+			c.addPlayer(test.reconnect.id, test.reconnect.ip, test.reconnect.name)
+			if muted := c.isMuted(test.reconnect.id); muted != test.expected {
+				t.Errorf("reconnected player muted: %v, expected %v", muted, test.expected)
+			}
+		})
+	}
+}
+
+func TestMuteUnknownPlayer(t *testing.T) {
+	c := newChatModerator()
+	if c.setMuted(5, true) {
+		t.Errorf("player that isn't connected was muted")
+	}
+	if c.isMuted(5) {
+		t.Errorf("player that isn't connected is muted")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"google.golang.org/protobuf/proto"
+	g "server/game-controllers"
+)
+
+func dungeonSettings() g.DungeonSettings {
+	return g.DungeonSettings{
+		MaxDepth:           config.MaxDungeonDepth,
+		StartingRoomId:     config.StartingRoomID,
+		SideRoomChance:     config.DungeonSideRoomChance,
+		TreasureRoomChance: config.DungeonTreasureRoomChance,
+	}
+}
+
+// isBossRoom reports whether room is the one at the end of the dungeon where the boss waits
+func isBossRoom(room *g.Room) bool {
+	return room.Type == g.BOSS_ROOM
+}
+
+func toProtoRoom(room *g.Room) *pb.Room {
+	protoRoom := &pb.Room{
+		X: int32(room.Position.X),
+		Y: int32(room.Position.Y),
+	}
+
+	doors := make([]uint64, len(room.Doors))
+	for i, door := range room.Doors {
+		doors[i] = uint64(door)
+	}
+
+	setExtUint(protoRoom, EXT_ROOM_ID, uint64(room.Id))
+	setExtUint(protoRoom, EXT_ROOM_DEPTH, uint64(room.Depth))
+	setExtString(protoRoom, EXT_ROOM_TYPE, room.Type.String())
+	setExtUints(protoRoom, EXT_ROOM_DOORS, doors)
+
+	return protoRoom
+}
+
+// validateRoomChange checks whether player can go to the requested room from the current one,
+// player trying to go somewhere else is sent back to the current room
+func validateRoomChange(msg *pb.StateUpdate, id uint32, conn *net.TCPConn) (*g.Room, bool) {
+	gameLock.Lock()
+	target, ok := game.roomTransition(g.Coordinate{X: int(msg.Room.GetX()), Y: int(msg.Room.GetY())})
+	current := game.currentRoom
+	gameLock.Unlock()
+
+	if ok {
+		return target, true
+	}
+
+	logger.Warn("Rejected room change", "playerId", id, "currentRoom", current.Id, "x", msg.Room.GetX(), "y", msg.Room.GetY())
+
+	responseMsg := &pb.StateUpdate{
+		Variant: pb.StateVariant_ROOM_CHANGED,
+		Room:    toProtoRoom(current),
+	}
+
+	serializedMsg, err := proto.Marshal(responseMsg)
+	if err != nil {
+		logger.Info("Failed to serialize room change correction", "error", err)
+		return nil, false
+	}
+
+	conn.Write(addPrefixAndPadding(serializedMsg))
+	return nil, false
+}
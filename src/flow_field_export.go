@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	g "server/game-controllers"
+)
+
+const FLOW_FIELD_EXPORT_DIR = "flowfield"
+
+var (
+	flowFieldEvery   = flag.Int("flowfield-every", 0, "export enemy flow fields every given number of map updates, 0 turns it off")
+	flowFieldDir     = flag.String("flowfield-dir", FLOW_FIELD_EXPORT_DIR, "directory flow fields are exported to")
+	flowFieldExports = flowFieldExporter{}
+)
+
+// flowFieldExporter decides which map updates have their flow fields exported,
+// admin can ask for the next update besides the ones exported periodically
+type flowFieldExporter struct {
+	lock      sync.Mutex
+	updates   int
+	requested string
+}
+
+func (e *flowFieldExporter) request(dir string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.requested = dir
+}
+
+// due returns directory flow fields of the current map update should be exported to
+func (e *flowFieldExporter) due() (string, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.updates++
+	if e.requested != "" {
+		dir := e.requested
+		e.requested = ""
+		return dir, true
+	}
+	if *flowFieldEvery > 0 && e.updates%*flowFieldEvery == 0 {
+		return *flowFieldDir, true
+	}
+	return "", false
+}
+
+// exportFlowFields writes every snapshot as JSON and PNG heatmap named after its tick and clearance
+func exportFlowFields(snapshots []g.FlowFieldSnapshot, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Info("Couldn't create flow field directory", "dir", dir, "error", err)
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		name := filepath.Join(dir, fmt.Sprintf("flowfield-%d-c%d", snapshot.Tick, snapshot.Clearance))
+		if err := writeFlowFieldFile(name+".json", snapshot.WriteJSON); err != nil {
+			logger.Info("Couldn't export flow field", "file", name+".json", "error", err)
+		}
+		if err := writeFlowFieldFile(name+".png", snapshot.WritePNG); err != nil {
+			logger.Info("Couldn't export flow field", "file", name+".png", "error", err)
+		}
+	}
+	logger.Info("Exported flow fields", "dir", dir, "count", len(snapshots))
+}
+
+func writeFlowFieldFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func flowFieldCommand(args []string, out io.Writer) {
+	dir := *flowFieldDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	flowFieldExports.request(dir)
+	fmt.Fprintf(out, "flow fields of the next map update will be exported to %s\n", dir)
+}
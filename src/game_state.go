@@ -1,16 +1,28 @@
 package main
 
 import (
+	"fmt"
 	"math/rand/v2"
+	"strings"
 	"time"
 
 	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	g "server/game-controllers"
 )
 
 type Player struct {
 	id         uint32
 	registered bool
 	items      []Item
+	name       string
+	class      string
+
+	hp                float64
+	life              lifeState
+	invulnerableUntil time.Time
+	downedUntil       time.Time
+	reviveProgress    float64
+	stats             playerStats
 }
 
 func (p *Player) toProtoPlayer() *pb.Player {
@@ -19,17 +31,32 @@ func (p *Player) toProtoPlayer() *pb.Player {
 		items[i] = p.items[i].intoProtoItem()
 	}
 
-	return &pb.Player{
+	player := &pb.Player{
 		Id:    p.id,
 		Items: items,
 	}
+	setExtString(player, EXT_PLAYER_NAME, p.name)
+	setExtString(player, EXT_PLAYER_CLASS, p.class)
+	setExtFloat(player, EXT_PLAYER_HP, float32(p.hp))
+	setExtFloat(player, EXT_PLAYER_MAX_HP, float32(config.MaxCharacterHP))
+	setExtString(player, EXT_PLAYER_LIFE_STATE, lifeStateNames[p.life])
+
+	return player
 }
 
 type Game struct {
-	players   []Player
-	generator *ItemGenerator
-	seed      int64
-	playerIDs *idPool
+	players     []Player
+	generator   *ItemGenerator
+	seed        int64
+	playerIDs   *idPool
+	dungeon     *g.Dungeon
+	currentRoom *g.Room
+	rooms       map[int]*roomState
+	startedAt   time.Time
+	runOver     bool
+	// inventories and progress of players from resumed run, given back when player with the same name joins
+	savedInventories map[string][]Item
+	savedProgress    map[string]playerSnapshot
 }
 
 func newGame() *Game {
@@ -38,15 +65,25 @@ func newGame() *Game {
 		players[i].registered = false
 	}
 
+	seed := time.Now().Unix()
+	dungeon := g.GenerateDungeon(seed, dungeonSettings())
+
 	return &Game{
-		players:   players,
-		generator: newGenerator(MAX_PLAYERS + 1),
-		seed:      time.Now().Unix(),
-		playerIDs: newIDPool(PLAYER_MIN_ID, PLAYER_MAX_ID),
+		players:     players,
+		generator:   newGenerator(MAX_PLAYERS + 1),
+		seed:        seed,
+		playerIDs:   newIDPool(PLAYER_MIN_ID, PLAYER_MAX_ID),
+		dungeon:     dungeon,
+		currentRoom: dungeon.GetStartingRoom(),
+		rooms:       make(map[int]*roomState),
+		startedAt:   time.Now(),
+
+		savedInventories: make(map[string][]Item),
+		savedProgress:    make(map[string]playerSnapshot),
 	}
 }
 
-func (g *Game) createInitialInfo() *pb.InitialInfo {
+func (g *Game) createInitialInfo(profile playerProfile) *pb.InitialInfo {
 	playerID := g.playerIDs.getID()
 
 	player := &g.players[playerID]
@@ -63,6 +100,11 @@ func (g *Game) createInitialInfo() *pb.InitialInfo {
 	player.id = playerID
 	player.registered = true
 	player.items = items
+	player.hp = config.DefaultCharacterHP
+	player.life = PLAYER_ALIVE
+	player.invulnerableUntil = time.Time{}
+	player.stats = newPlayerStats(time.Now())
+	g.applyProfile(player, profile)
 
 	connectedPlayers := make([]*pb.Player, 0, MAX_PLAYERS)
 
@@ -72,12 +114,51 @@ func (g *Game) createInitialInfo() *pb.InitialInfo {
 		}
 	}
 
-	return &pb.InitialInfo{
+	initialInfo := &pb.InitialInfo{
 		Player:           player.toProtoPlayer(),
 		Seed:             g.seed,
 		NextItem:         g.requestItemGenerator(playerID).intoProtoItem(),
 		ConnectedPlayers: connectedPlayers,
 	}
+
+	for _, room := range g.dungeon.GetRooms() {
+		addExtMessage(initialInfo, EXT_DUNGEON_ROOMS, toProtoRoom(room))
+	}
+	setExtMessage(initialInfo, EXT_CURRENT_ROOM, toProtoRoom(g.currentRoom))
+
+	return initialInfo
+}
+
+// applyProfile gives player chosen name and class, player of the resumed run with the same name
+// gets its inventory and progress back. Player without name gets default one.
+func (g *Game) applyProfile(player *Player, profile playerProfile) {
+	player.name = profile.name
+	player.class = profile.class
+
+	if savedItems, ok := g.savedInventories[profile.name]; ok && profile.name != "" {
+		for _, item := range player.items {
+			g.generator.returnItemID(item.id)
+		}
+		player.items = savedItems
+		delete(g.savedInventories, profile.name)
+	}
+	if progress, ok := g.savedProgress[profile.name]; ok && profile.name != "" {
+		progress.restore(player, time.Now())
+		delete(g.savedProgress, profile.name)
+	}
+	if player.name == "" {
+		player.name = fmt.Sprintf("Player %d", player.id)
+	}
+}
+
+// introduce applies profile sent by player after joining and returns update telling everybody about it
+func (g *Game) introduce(playerID uint32, profile playerProfile) *pb.StateUpdate {
+	player := &g.players[playerID]
+	g.applyProfile(player, profile)
+	return &pb.StateUpdate{
+		Variant: PLAYER_PROFILE,
+		Player:  player.toProtoPlayer(),
+	}
 }
 
 func (g *Game) removePlayer(playerID uint32) {
@@ -88,10 +169,72 @@ func (g *Game) removePlayer(playerID uint32) {
 	}
 	player.registered = false
 	player.items = nil
+	player.name = ""
+	player.class = ""
 
 	g.playerIDs.returnID(playerID)
 }
 
+// threatModifier returns how much threat hits of the player cause compared to other classes,
+// gameLock has to be locked by the caller
+func (g *Game) threatModifier(playerID uint32) float64 {
+	if int(playerID) >= len(g.players) {
+		return 1
+	}
+	if modifier, ok := config.ClassThreat[g.players[playerID].class]; ok {
+		return modifier
+	}
+	return 1
+}
+
+// isNameTaken reports whether any connected player uses given name, letter case is ignored
+func (g *Game) isNameTaken(name string) bool {
+	for _, p := range g.players {
+		if p.registered && strings.EqualFold(p.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// playersNamed returns ids of connected players with given name, names are compared without case
+func (g *Game) playersNamed(name string) []uint32 {
+	ids := make([]uint32, 0)
+	for _, p := range g.players {
+		if p.registered && strings.EqualFold(p.name, name) {
+			ids = append(ids, p.id)
+		}
+	}
+	return ids
+}
+
+func (g *Game) playerName(playerID uint32) string {
+	return g.players[playerID].name
+}
+
+// roomTransition returns room at given position if there is a door to it from the current room
+func (g *Game) roomTransition(position g.Coordinate) (*g.Room, bool) {
+	target, ok := g.dungeon.GetRoomAt(position)
+	if !ok || !g.dungeon.CanMove(g.currentRoom, target) {
+		return nil, false
+	}
+	return target, true
+}
+
+func (g *Game) enterRoom(room *g.Room) {
+	g.currentRoom = room
+}
+
+// currentRoomState returns state of the room players are in, it's created on the first visit
+func (g *Game) currentRoomState() *roomState {
+	state, ok := g.rooms[g.currentRoom.Id]
+	if !ok {
+		state = newRoomState()
+		g.rooms[g.currentRoom.Id] = state
+	}
+	return state
+}
+
 func (g *Game) getProtoPlayer(playerID uint32) *pb.Player {
 	return g.players[playerID].toProtoPlayer()
 }
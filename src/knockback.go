@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"github.com/ungerik/go3d/vec2"
+	g "server/game-controllers"
+)
+
+var lastKnockbackUpdate = time.Now()
+
+// knockBack pushes enemy away from player that hit it, enemiesLock has to be locked by the caller
+func knockBack(enemy *g.Enemy, playerId uint32, player *pb.Player) {
+	if !config.ApplyKnockback {
+		return
+	}
+
+	x, y := validator.trustedPosition(playerId, player.GetPositionX(), player.GetPositionY())
+	direction := vec2.T{
+		float32(enemy.GetPosition().X*SCALLING_FACTOR) - x,
+		float32(enemy.GetPosition().Y*SCALLING_FACTOR) - y,
+	}
+	if direction.Length() == 0 {
+		return
+	}
+
+	direction.Normalize()
+	enemy.ApplyKnockback(direction.Scaled(float32(config.DefaultEnemyKnockbackForce / SCALLING_FACTOR)))
+}
+
+// updateKnockbacks moves enemies that are knocked back and returns ids of them, positions of these
+// enemies are decided by the server. enemiesLock has to be locked by the caller.
+func updateKnockbacks() map[uint32]bool {
+	now := time.Now()
+	dt := now.Sub(lastKnockbackUpdate).Seconds()
+	lastKnockbackUpdate = now
+
+	algorithm.Mutex.RLock()
+	defer algorithm.Mutex.RUnlock()
+
+	pushed := make(map[uint32]bool)
+	for id, enemy := range enemies {
+		if !enemy.IsKnockedBack() {
+			continue
+		}
+
+		algorithm.UpdateKnockback(enemy, dt)
+		enemy.SetDirection(vec2.T{0, 0})
+		pushed[id] = true
+	}
+	return pushed
+}
+
+// setKnockbackPosition tells clients where enemy pushed by knockback is
+func setKnockbackPosition(msg *pb.Enemy, enemy *g.Enemy) {
+	setExtFloat(msg, EXT_ENEMY_POSITION_X, float32(enemy.GetPosition().X*SCALLING_FACTOR))
+	setExtFloat(msg, EXT_ENEMY_POSITION_Y, float32(enemy.GetPosition().Y*SCALLING_FACTOR))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math/rand/v2"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	g "server/game-controllers"
+	u "server/utils"
+)
+
+// dropLoot rolls loot table of killed enemy and places items where it died,
+// connLock has to be read locked by the caller
+func dropLoot(enemy *g.Enemy) {
+	enemyConfig, ok := enemyConfigByName(enemy.GetName())
+	if !ok || enemyConfig.Loot == nil {
+		return
+	}
+
+	x := float32(enemy.GetPosition().X * SCALLING_FACTOR)
+	y := float32(enemy.GetPosition().Y * SCALLING_FACTOR)
+
+	gameLock.Lock()
+	items := game.rollLoot(*enemyConfig.Loot)
+	state := game.currentRoomState()
+	for _, item := range items {
+		state.items[item.id] = floorItem{item: item, x: x, y: y}
+	}
+	gameLock.Unlock()
+
+	for _, item := range items {
+		logger.Info("Enemy dropped item", "enemyId", enemy.GetId(), "itemId", item.id, "type", item.variant.String())
+
+		protoItem := item.intoProtoItem()
+		setExtFloat(protoItem, EXT_ITEM_POSITION_X, x)
+		setExtFloat(protoItem, EXT_ITEM_POSITION_Y, y)
+		broadcastUpdate(&pb.StateUpdate{
+			Variant: ITEM_DROPPED,
+			Item:    protoItem,
+		})
+	}
+}
+
+// rollLoot creates items dropped from loot table, gameLock has to be locked by the caller
+func (g *Game) rollLoot(loot u.LootTable) []Item {
+	variants := make([]pb.ItemType, 0, len(loot.Guaranteed)+1)
+	for _, name := range loot.Guaranteed {
+		if variant, ok := itemTypeByName(name); ok {
+			variants = append(variants, variant)
+		}
+	}
+	if rand.Float64() < loot.DropChance {
+		if variant, ok := pickWeighted(loot.Weights); ok {
+			variants = append(variants, variant)
+		}
+	}
+
+	items := make([]Item, 0, len(variants))
+	for _, variant := range variants {
+		items = append(items, Item{
+			id:      g.generator.requestItemID(),
+			r:       rand.Uint32(),
+			variant: variant,
+		})
+	}
+	return items
+}
+
+func pickWeighted(weights map[string]float64) (pb.ItemType, bool) {
+	// types are walked in enum order, ranging over the map would give them different order every time
+	total := 0.0
+	for variant := pb.ItemType_WEAPON; variant <= pb.ItemType_HELMET; variant++ {
+		total += max(0, weights[variant.String()])
+	}
+	if total <= 0 {
+		return pb.ItemType_UNKNOWN, false
+	}
+
+	roll := rand.Float64() * total
+	for variant := pb.ItemType_WEAPON; variant <= pb.ItemType_HELMET; variant++ {
+		roll -= max(0, weights[variant.String()])
+		if roll < 0 {
+			return variant, true
+		}
+	}
+	return pb.ItemType_UNKNOWN, false
+}
+
+func itemTypeByName(name string) (pb.ItemType, bool) {
+	value, ok := pb.ItemType_value[name]
+	if !ok || pb.ItemType(value) == pb.ItemType_UNKNOWN {
+		logger.Info("Loot table contains unknown item type", "type", name)
+		return pb.ItemType_UNKNOWN, false
+	}
+	return pb.ItemType(value), true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	u "server/utils"
+)
+
+func TestRollLoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		loot     u.LootTable
+		expected []pb.ItemType
+	}{
+		{"nothing", u.LootTable{}, []pb.ItemType{}},
+		{"guaranteed only", u.LootTable{DropChance: 0, Weights: map[string]float64{"WEAPON": 1}, Guaranteed: []string{"ARMOUR", "POTION"}},
+			[]pb.ItemType{pb.ItemType_ARMOUR, pb.ItemType_POTION}},
+		{"certain drop", u.LootTable{DropChance: 1, Weights: map[string]float64{"HELMET": 1, "WEAPON": 0}},
+			[]pb.ItemType{pb.ItemType_HELMET}},
+		{"no usable weights", u.LootTable{DropChance: 1, Weights: map[string]float64{"WEAPON": -1, "SWORD": 5}}, []pb.ItemType{}},
+		{"unknown guaranteed type", u.LootTable{Guaranteed: []string{"UNKNOWN", "SHIELD", "POTION"}}, []pb.ItemType{pb.ItemType_POTION}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game = newGame()
+			for roll := 0; roll < 20; roll++ {
+				items := game.rollLoot(test.loot)
+				if len(items) != len(test.expected) {
+					t.Fatalf("rolled %d items, expected %v", len(items), test.expected)
+				}
+				for i, item := range items {
+					if item.variant != test.expected[i] {
+						t.Errorf("item %d is %v, expected %v", i, item.variant, test.expected[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRolledItemsHaveUniqueIds(t *testing.T) {
+	game = newGame()
+	loot := u.LootTable{DropChance: 1, Weights: map[string]float64{"WEAPON": 1}, Guaranteed: []string{"POTION"}}
+
+	ids := make(map[uint32]bool)
+	for roll := 0; roll < 50; roll++ {
+		for _, item := range game.rollLoot(loot) {
+			if ids[item.id] {
+				t.Fatalf("item id %d was given twice", item.id)
+			}
+			ids[item.id] = true
+		}
+	}
+}
+
+func TestPickWeightedFollowsWeights(t *testing.T) {
+	weights := map[string]float64{"WEAPON": 3, "POTION": 1, "HELMET": 0}
+	const rolls = 20000
+
+	picked := make(map[pb.ItemType]int)
+	for i := 0; i < rolls; i++ {
+		variant, ok := pickWeighted(weights)
+		if !ok {
+			t.Fatalf("nothing was picked")
+		}
+		picked[variant]++
+	}
+
+	if picked[pb.ItemType_HELMET] != 0 || len(picked) != 2 {
+		t.Errorf("picked types %v, only WEAPON and POTION have weight", picked)
+	}
+	if share := float64(picked[pb.ItemType_WEAPON]) / rolls; math.Abs(share-0.75) > 0.03 {
+		t.Errorf("WEAPON was picked in %.3f of rolls, expected 0.75", share)
+	}
+}
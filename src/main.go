@@ -6,6 +6,7 @@ import (
 	"compress/zlib"
 	"errors"
 	"flag"
+	"fmt"
 	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
 	"google.golang.org/protobuf/proto"
 	"io"
@@ -39,6 +40,7 @@ const (
 
 var (
 	ipString  = flag.String("a", "127.0.0.1", "server ip address")
+	resume    = flag.String("resume", "", "resume run saved in given file")
 	ip        = net.ParseIP("127.0.0.1")
 	addrPorts = make(map[uint32]netip.AddrPort, MAX_PLAYERS+1)
 	tcpConns  = make(map[uint32]*net.TCPConn, MAX_PLAYERS+1)
@@ -47,17 +49,27 @@ var (
 	connLock  = sync.RWMutex{}
 	enemyIds  = newIDPool(ENEMY_MIN_ID, ENEMY_MAX_ID)
 
-	collisions        = make([]g.Coordinate, 0)
 	enemies           = make(map[uint32]*g.Enemy)
+	enemiesLock       = sync.Mutex{}
 	players           = make(map[uint32]g.Coordinate)
 	algorithm         = g.NewAIAlgorithm()
 	isSpawned         atomic.Bool
-	isMapUpdated      atomic.Bool
 	spawnedEnemiesIds = make([]uint32, 0)
+	validator         = newMovementValidator()
+	maps              = newMapRegistry()
+	limiter           = newRateLimiter()
+	chat              = newChatModerator()
+	kicks             = newKickQueue()
+	transitionVote    = newRoomTransitionVote()
+	bans              *banList
 	config            = u.Config{}
 	logger            = slog.New(slog.NewTextHandler(os.Stderr, nil))
 )
 
+// unintroduced are players whose first message wasn't read yet, they are added under write lock of connLock
+// and their profile is decided by the tcp loop from the first message they send
+var unintroduced = make(map[uint32]bool)
+
 type SingleFlight struct {
 	lock chan struct{}
 }
@@ -78,13 +90,6 @@ func (s *SingleFlight) TryExecute(f func()) bool {
 }
 
 func listenTCP() {
-	stateUpdate := &pb.StateUpdate{
-		Player: &pb.Player{
-			Id: 0,
-		},
-		Variant: pb.StateVariant_CONNECTED,
-	}
-
 	addr := net.TCPAddr{
 		IP:   ip,
 		Port: SERVER_PORT,
@@ -104,36 +109,57 @@ func listenTCP() {
 		if err != nil {
 			log.Printf("Failed to accept tcp connection: %v\n", err)
 		} else {
-			gameLock.Lock()
-			initialInfo := game.createInitialInfo()
-			id := initialInfo.Player.Id
-			stateUpdate.Player = game.getProtoPlayer(id)
-			gameLock.Unlock()
-
-			// create message with prefix byte length of update
-			// and the update itself
-			serializedMsg, _ := proto.Marshal(stateUpdate)
-			encoded := addPrefixAndPadding(serializedMsg)
+			// slow client can't block accepting other players
+			go joinPlayer(conn)
+		}
+	}
+}
 
-			connLock.Lock()
-			for otherID, c := range tcpConns {
-				log.Printf("comm: %d %d\n", id, otherID)
+func joinPlayer(conn *net.TCPConn) {
+	remoteIP := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	if reason, banned := bans.check(remoteIP, ""); banned {
+		log.Printf("rejected banned address: %s\n", remoteIP)
+		conn.Write(disconnectMessage(0, reason))
+		conn.Close()
+		return
+	}
 
-				// inform connected players of new one
-				c.Write(encoded)
-			}
+	// player joins right away, chosen name and class come with the first message it sends
+	gameLock.Lock()
+	initialInfo := game.createInitialInfo(defaultProfile())
+	id := initialInfo.Player.Id
+	stateUpdate := &pb.StateUpdate{
+		Player:  game.getProtoPlayer(id),
+		Variant: pb.StateVariant_CONNECTED,
+	}
+	name := game.playerName(id)
+	gameLock.Unlock()
+	chat.addPlayer(id, remoteIP, "")
 
-			// these will be monitored (we're assuming that closing conn means losing connection)
-			tcpConns[id] = conn
-			connLock.Unlock()
+	// create message with prefix byte length of update
+	// and the update itself
+	serializedMsg, _ := proto.Marshal(stateUpdate)
+	encoded := addPrefixAndPadding(serializedMsg)
 
-			// inform player of current game state
-			encoded, _ = proto.Marshal(initialInfo)
-			log.Printf("connected: %d\n", id)
+	connLock.Lock()
+	for otherID, c := range tcpConns {
+		log.Printf("comm: %d %d\n", id, otherID)
 
-			conn.Write(encoded)
-		}
+		// inform connected players of new one
+		c.Write(encoded)
 	}
+
+	// these will be monitored (we're assuming that closing conn means losing connection)
+	tcpConns[id] = conn
+	kicks.connect(id)
+	unintroduced[id] = true
+	connLock.Unlock()
+
+	// inform player of current game state
+	encoded, _ = proto.Marshal(initialInfo)
+	log.Printf("connected: %d (%s)\n", id, name)
+
+	conn.Write(encoded)
 }
 
 func handleTCP(userCh chan uint32, graphCh chan bool) {
@@ -141,48 +167,26 @@ func handleTCP(userCh chan uint32, graphCh chan bool) {
 
 	for {
 		connLock.RLock()
+		if transitionVote.expire(time.Now()) {
+			broadcastTransitionCancelled()
+		}
+
 		for id, conn := range tcpConns {
 			conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
 			reader := bufio.NewReaderSize(conn, BUF_SIZE)
 
+			if reason, kicked := kicks.take(id); kicked {
+				disconnectPlayer(id, reason, userCh)
+				resolveTransitionVote(graphCh)
+				continue
+			}
+
 			_, err := reader.Peek(PREFIX_SIZE)
 
 			if errors.Is(err, io.EOF) {
-				userCh <- id
-
-				gameLock.Lock()
-				game.removePlayer(id)
-				gameLock.Unlock()
-
-				msg := &pb.StateUpdate{
-					Player:  &pb.Player{Id: id},
-					Variant: pb.StateVariant_DISCONNECTED,
-				}
-
-				for otherID, c := range tcpConns {
-					if otherID != id {
-						serializedMsg, _ := proto.Marshal(msg)
-						encoded := addPrefixAndPadding(serializedMsg)
-
-						c.Write(encoded)
-					}
-				}
-				log.Printf("disconnected %d\n", id)
-
-				connLock.RUnlock()
-				connLock.Lock()
-				if conn, ok := tcpConns[id]; ok {
-					conn.Close()
-					delete(tcpConns, id)
-				}
-
-				if len(tcpConns) == 0 {
-					gameLock.Lock()
-					game = newGame()
-					gameLock.Unlock()
-				}
-				connLock.Unlock()
-				connLock.RLock()
+				disconnectPlayer(id, "", userCh)
+				resolveTransitionVote(graphCh)
+				continue
 			}
 
 			if err != nil {
@@ -199,8 +203,12 @@ func handleTCP(userCh chan uint32, graphCh chan bool) {
 				logger.Info("Couldn't unmarshall prefix message", "error", err)
 			}
 
-			size := prefixMsg.GetBytes() - DIFF
-			_, err = reader.Peek(int(size))
+			size := int(prefixMsg.GetBytes()) - DIFF
+			if !limiter.allowSize(id, size) {
+				continue
+			}
+
+			_, err = reader.Peek(size)
 			if err != nil {
 				logger.Info("Not enough bytes to read", "message size", size, "error", err)
 				continue
@@ -214,50 +222,179 @@ func handleTCP(userCh chan uint32, graphCh chan bool) {
 					continue
 				}
 
-				for _, update := range updateSeries.GetUpdates() {
-					logger.Info("Incoming state update", "update", update)
-
-					switch update.Variant {
-					case pb.StateVariant_REQUEST_ITEM_GENERATOR:
-						gameLock.Lock()
-						update.Item = game.requestItemGenerator(update.Player.Id).intoProtoItem()
-						gameLock.Unlock()
-						serializedMsg, _ := proto.Marshal(update)
-						encoded := addPrefixAndPadding(serializedMsg)
-
-						conn.Write(encoded)
-					case pb.StateVariant_MAP_DIMENSIONS_UPDATE:
-						if !isMapUpdated.Load() {
-							isMapUpdated.Store(true)
-							handleMapDimensionUpdate(update.CompressedMapDimensionsUpdate)
-						}
-					case pb.StateVariant_ROOM_CHANGED:
-						graphCh <- false
-						handleRoomChange(update, id)
-					case pb.StateVariant_SPAWN_ENEMY_REQUEST:
-						if !isSpawned.Load() {
-							handleSpawnEnemyRequest(update.EnemySpawnerPositions)
-						}
-						handleSendSpawnedEnemies()
-						graphCh <- true
-					default:
-						for otherID, otherConn := range tcpConns {
-							if id != otherID {
-								serializedMsg, _ := proto.Marshal(update)
-								encoded := addPrefixAndPadding(serializedMsg)
-
-								otherConn.Write(encoded)
-							}
-						}
+				if unintroduced[id] {
+					delete(unintroduced, id)
+					if !introducePlayer(updateSeries, id, conn) {
+						continue
 					}
-					continue
 				}
+
+				handleStateUpdates(updateSeries, id, conn, graphCh)
 			}
 		}
 		connLock.RUnlock()
 	}
 }
 
+// handleStateUpdates reacts to updates sent by player, connLock has to be read locked by the caller
+func handleStateUpdates(updateSeries *pb.StateUpdateSeries, id uint32, conn *net.TCPConn, graphCh chan bool) {
+	for _, update := range updateSeries.GetUpdates() {
+		logger.Info("Incoming state update", "update", update)
+
+		if !limiter.allow(id, stateVariantName(update.Variant), time.Now()) {
+			continue
+		}
+
+		switch update.Variant {
+		case pb.StateVariant_REQUEST_ITEM_GENERATOR:
+			gameLock.Lock()
+			update.Item = game.requestItemGenerator(update.Player.Id).intoProtoItem()
+			gameLock.Unlock()
+			serializedMsg, _ := proto.Marshal(update)
+			encoded := addPrefixAndPadding(serializedMsg)
+
+			conn.Write(encoded)
+		case pb.StateVariant_MAP_DIMENSIONS_UPDATE:
+			handleMapDimensionUpdate(update)
+		case pb.StateVariant_ROOM_CHANGED:
+			room, ok := validateRoomChange(update, id, conn)
+			if !ok {
+				continue
+			}
+
+			transitionVote.vote(id, room, time.Now())
+			resolveTransitionVote(graphCh)
+		case pb.StateVariant_SPAWN_ENEMY_REQUEST:
+			if !isSpawned.Load() {
+				handleSpawnEnemyRequest(update.EnemySpawnerPositions)
+			}
+			handleSendSpawnedEnemies()
+			signalGraph(graphCh, true)
+		case CHAT_MESSAGE:
+			handleChatMessage(update, id, conn)
+		case pb.StateVariant_ENEMY_GOT_HIT_UPDATE:
+			// downed and dead players can't fight
+			if isPlayerAlive(id) {
+				relayUpdate(update, id)
+				handleEnemyHit(update, id)
+			}
+		case pb.StateVariant_PLAYER_DIED:
+			// health is tracked by the server, players can't decide they died
+			logger.Debug("Ignored death reported by player", "playerId", id)
+		case pb.StateVariant_CHEST_OPENED, pb.StateVariant_ITEM_EQUIPPED, pb.StateVariant_ROOM_CLEARED, ITEM_DROPPED:
+			handleFloorItemUpdate(update, id)
+			relayUpdate(update, id)
+		default:
+			relayUpdate(update, id)
+		}
+	}
+}
+
+// relayUpdate passes update to everybody except its sender, connLock has to be read locked by the caller
+func relayUpdate(update *pb.StateUpdate, id uint32) {
+	for otherID, otherConn := range tcpConns {
+		if id != otherID {
+			serializedMsg, _ := proto.Marshal(update)
+			encoded := addPrefixAndPadding(serializedMsg)
+
+			otherConn.Write(encoded)
+		}
+	}
+}
+
+// disconnectPlayer removes player from the game and informs everybody else about it, player is told
+// why it was disconnected if reason is given, connLock has to be read locked by the caller and stays
+// read locked afterwards
+func disconnectPlayer(id uint32, reason string, userCh chan uint32) {
+	userCh <- id
+	kicks.disconnect(id)
+
+	if conn, ok := tcpConns[id]; ok && reason != "" {
+		conn.Write(disconnectMessage(id, reason))
+	}
+
+	gameLock.Lock()
+	name := game.playerName(id)
+	game.removePlayer(id)
+	gameLock.Unlock()
+	validator.removePlayer(id)
+	limiter.removePlayer(id)
+	chat.removePlayer(id)
+	transitionVote.removePlayer(id)
+
+	msg := &pb.StateUpdate{
+		Player:  &pb.Player{Id: id},
+		Variant: pb.StateVariant_DISCONNECTED,
+	}
+
+	leftMsg := systemMessage(fmt.Sprintf("%s left", name))
+	if reason != "" {
+		leftMsg = systemMessage(fmt.Sprintf("%s was kicked: %s", name, reason))
+	}
+	for otherID, c := range tcpConns {
+		if otherID != id {
+			serializedMsg, _ := proto.Marshal(msg)
+			encoded := addPrefixAndPadding(serializedMsg)
+
+			c.Write(encoded)
+			c.Write(leftMsg)
+		}
+	}
+	log.Printf("disconnected %d\n", id)
+
+	connLock.RUnlock()
+	connLock.Lock()
+	if conn, ok := tcpConns[id]; ok {
+		conn.Close()
+		delete(tcpConns, id)
+	}
+	delete(unintroduced, id)
+
+	if len(tcpConns) == 0 {
+		gameLock.Lock()
+		game = newGame()
+		gameLock.Unlock()
+		maps.clear()
+
+		enemiesLock.Lock()
+		enemies = make(map[uint32]*g.Enemy)
+		isSpawned.Store(false)
+		clearBosses()
+		clearProjectiles()
+		enemiesLock.Unlock()
+		transitionVote.reset()
+	}
+	connLock.Unlock()
+	connLock.RLock()
+}
+
+// disconnectMessage creates encoded update telling player that it is being disconnected
+func disconnectMessage(id uint32, reason string) []byte {
+	msg := &pb.StateUpdate{
+		Player:  &pb.Player{Id: id},
+		Variant: pb.StateVariant_DISCONNECTED,
+	}
+	setExtString(msg, EXT_REASON, reason)
+
+	serializedMsg, err := proto.Marshal(msg)
+	if err != nil {
+		logger.Info("Failed to serialize disconnect message", "error", err)
+	}
+
+	return addPrefixAndPadding(serializedMsg)
+}
+
+// signalGraph tells the udp loop whether enemies should follow the graph. The tcp loop sends it while holding
+// connLock, so it mustn't block: value the udp loop didn't take yet is replaced with the new one.
+// Channel has to be buffered and the tcp loop has to be the only sender.
+func signalGraph(graphCh chan bool, graph bool) {
+	select {
+	case <-graphCh:
+	default:
+	}
+	graphCh <- graph
+}
+
 func handleUDP(userCh chan uint32, graphCh chan bool, sf *SingleFlight) {
 	addr := net.UDPAddr{
 		Port: SERVER_PORT,
@@ -299,27 +436,35 @@ func handleUDP(userCh chan uint32, graphCh chan bool, sf *SingleFlight) {
 				continue
 			}
 
+			senderID, ok := udpSender(movementUpdate, sender.AddrPort())
+			if !ok {
+				continue
+			}
+			if !limiter.allow(senderID, movementUpdate.Variant.String(), time.Now()) {
+				continue
+			}
+
 			switch movementUpdate.Variant {
 			case pb.MovementVariant_PLAYER_MOVEMENT_UPDATE:
-				senderAddrPort := sender.AddrPort()
-				id := movementUpdate.EntityId
-
-				connLock.RLock()
-				// skip packets from disconnected player
-				if _, ok := tcpConns[id]; !ok {
-					continue
-				}
-				connLock.RUnlock()
-
-				if val, ok := addrPorts[id]; !ok || val != senderAddrPort {
-					addrPorts[id] = senderAddrPort
+				id := senderID
+				msg := b[:n]
+				x, y, accepted := validator.validate(id, movementUpdate.PositionX, movementUpdate.PositionY, time.Now())
+				if !accepted || x != movementUpdate.PositionX || y != movementUpdate.PositionY {
+					// other players get position the server agrees with
+					movementUpdate.PositionX = x
+					movementUpdate.PositionY = y
+					msg, err = proto.Marshal(movementUpdate)
+					if err != nil {
+						logger.Info("Failed to serialize corrected player movement", "error", err)
+						continue
+					}
 				}
 
 				// pass update to other players
 				for otherID, addrPort := range addrPorts {
 					if otherID != id {
 						udpAddr := net.UDPAddrFromAddrPort(addrPort)
-						conn.WriteToUDP(b[:n], udpAddr)
+						conn.WriteToUDP(msg, udpAddr)
 					}
 				}
 			case pb.MovementVariant_MAP_UPDATE:
@@ -333,12 +478,62 @@ func handleUDP(userCh chan uint32, graphCh chan bool, sf *SingleFlight) {
 	}
 }
 
+// udpSender returns id of connected player that sent the packet. Address of the player is learned from its
+// movement updates, but only when they come from the ip address of its tcp connection, so packets with
+// somebody else's id are dropped before they count against that player's limits.
+func udpSender(update *pb.MovementUpdate, addrPort netip.AddrPort) (uint32, bool) {
+	id := update.EntityId
+	if update.Variant != pb.MovementVariant_PLAYER_MOVEMENT_UPDATE {
+		id = playerIDByAddrPort(addrPort)
+	}
+
+	connLock.RLock()
+	conn, connected := tcpConns[id]
+	connLock.RUnlock()
+	if !connected {
+		return 0, false
+	}
+	if known, ok := addrPorts[id]; ok && known == addrPort {
+		return id, true
+	}
+
+	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if update.Variant != pb.MovementVariant_PLAYER_MOVEMENT_UPDATE || !ok ||
+		tcpAddr.AddrPort().Addr().Unmap() != addrPort.Addr().Unmap() {
+		return 0, false
+	}
+	addrPorts[id] = addrPort
+	return id, true
+}
+
+// playerIDByAddrPort returns id of player sending udp packets from given address, 0 for unknown senders
+func playerIDByAddrPort(addrPort netip.AddrPort) uint32 {
+	for id, playerAddrPort := range addrPorts {
+		if playerAddrPort == addrPort {
+			return id
+		}
+	}
+	return 0
+}
+
 func handleSendSpawnedEnemies() {
+	enemiesLock.Lock()
+	spawnedEnemies := make([]*g.Enemy, 0, len(enemies))
+	for _, enemy := range enemies {
+		spawnedEnemies = append(spawnedEnemies, enemy)
+	}
+	enemiesLock.Unlock()
+
+	sendEnemies(spawnedEnemies)
+}
+
+// sendEnemies tells every player to spawn enemies, connLock has to be read locked by the caller
+func sendEnemies(enemiesToSend []*g.Enemy) {
 	responseMsg := &pb.StateUpdate{
 		Variant: pb.StateVariant_SPAWN_ENEMY_REQUEST,
 	}
 
-	for _, enemy := range enemies {
+	for _, enemy := range enemiesToSend {
 		textureData := enemy.GetTextureData()
 		collisionData := enemy.GetCollisionData()
 		protoEnemy := &pb.Enemy{
@@ -381,15 +576,36 @@ func handleSendSpawnedEnemies() {
 	}
 }
 
-func handleRoomChange(msg *pb.StateUpdate, id uint32) {
-	enemies = make(map[uint32]*g.Enemy)
+func handleRoomChange(room *g.Room) {
+	gameLock.Lock()
+	previous := game.currentRoomState()
+	game.enterRoom(room)
+	next := game.currentRoomState()
+
+	// enemies that are still alive wait for players to come back
+	enemiesLock.Lock()
+	previous.enemies = enemies
+	previous.spawned = isSpawned.Load()
+	enemies = next.enemies
+	isSpawned.Store(next.spawned)
 	players = make(map[uint32]g.Coordinate)
-	isSpawned.Store(false)
-	isMapUpdated.Store(false)
+	clearProjectiles()
+	enemiesLock.Unlock()
+
+	roomStateMsg := next.toProtoRoomState(room)
+	healthUpdates := game.respawnDeadPlayers()
+	gameLock.Unlock()
+
+	validator.resetRoom()
+	// clients upload layout of the room only when its hash differs from the one the server knows
+	if m, ok := maps.get(room.Id); ok {
+		setExtString(roomStateMsg, EXT_MAP_HASH, m.hash)
+		useRoomMap(room.Id, m)
+	}
 
 	responseMsg := pb.StateUpdate{
 		Variant: pb.StateVariant_ROOM_CHANGED,
-		Room:    msg.Room,
+		Room:    toProtoRoom(room),
 	}
 
 	serializedMsg, err := proto.Marshal(&responseMsg)
@@ -397,10 +613,20 @@ func handleRoomChange(msg *pb.StateUpdate, id uint32) {
 		logger.Info("Failed to serialize enemy spawn request response", "error", err)
 	}
 
+	// the last voter goes to the room together with everybody else
 	encoded := addPrefixAndPadding(serializedMsg)
-	for otherID, otherConn := range tcpConns {
-		if id != otherID {
-			otherConn.Write(encoded)
+	for _, conn := range tcpConns {
+		conn.Write(encoded)
+	}
+
+	broadcastUpdate(roomStateMsg)
+	for _, update := range healthUpdates {
+		broadcastUpdate(update)
+	}
+
+	if config.AutosaveOnRoomChange {
+		if err = saveRun(config.SaveFile); err != nil {
+			logger.Info("Failed to save run", "file", config.SaveFile, "error", err)
 		}
 	}
 }
@@ -417,36 +643,47 @@ func addPrefixAndPadding(serializedMsg []byte) []byte {
 	return append(serialisedPrefix, serializedMsg...)
 }
 
-func handleMapDimensionUpdate(update []byte) {
-	decompressedUpdate := decompressMessage(update)
+// handleMapDimensionUpdate registers layout of the room uploaded by client, layout is sent for the current room
+// unless the room is given in the update. Layouts uploaded before are not parsed again.
+func handleMapDimensionUpdate(update *pb.StateUpdate) {
+	layout := decompressMessage(update.CompressedMapDimensionsUpdate)
+	if layout == nil {
+		return
+	}
 
-	var maxHeight int32 = 0
-	var maxWidth int32 = 0
-	var minHeight int32 = math.MaxInt32
-	var minWidth int32 = math.MaxInt32
+	gameLock.Lock()
+	roomID := game.currentRoom.Id
+	gameLock.Unlock()
+	if update.Room != nil {
+		if id, ok := getExtUint(update.Room, EXT_ROOM_ID); ok {
+			roomID = int(id)
+		}
+	}
 
-	var mapDimensionUpdate pb.MapDimensionsUpdate
-	if err := proto.Unmarshal(decompressedUpdate, &mapDimensionUpdate); err != nil {
-		logger.Info("Failed to unmarshal decompressedUpd")
+	m, changed, err := maps.register(roomID, layout)
+	if err != nil {
+		logger.Info("Failed to parse map dimensions update", "room", roomID, "error", err)
+		return
+	}
+	if changed {
+		useRoomMap(roomID, m)
 	}
+}
 
-	for _, obstacle := range mapDimensionUpdate.Obstacles {
-		collisions = append(collisions, convertToCollision(obstacle))
-		maxHeight = max(maxHeight, int32(obstacle.Top))
-		maxWidth = max(maxWidth, int32(obstacle.Left))
-		minHeight = min(minHeight, int32(obstacle.Top))
-		minWidth = min(minWidth, int32(obstacle.Left))
+// useRoomMap switches movement validation and pathfinding to the layout when its room is the current one
+func useRoomMap(roomID int, m *roomMap) {
+	gameLock.Lock()
+	defer gameLock.Unlock()
+	if game.currentRoom.Id != roomID {
+		return
 	}
 
+	validator.setBounds(m.bounds)
+
 	algorithm.Mutex.Lock()
 	defer algorithm.Mutex.Unlock()
-	algorithm.SetWidth(int((maxWidth-minWidth)/SCALLING_FACTOR) + 1)
-	algorithm.SetHeight(int((maxHeight-minHeight)/SCALLING_FACTOR) + 1)
-	algorithm.SetOffset(int(minWidth/SCALLING_FACTOR), int(minHeight/SCALLING_FACTOR))
-
-	algorithm.SetCollision(collisions)
-	algorithm.InitGraph()
-	collisions = make([]g.Coordinate, 0)
+	algorithm.SetGrid(m.grid)
+	algorithm.SetSearchMargin(config.PathfindingMargin)
 }
 
 func decompressMessage(update []byte) []byte {
@@ -465,28 +702,61 @@ func decompressMessage(update []byte) []byte {
 }
 
 func convertToProtoEnemy(enemy *g.Enemy) *pb.Enemy {
-	return &pb.Enemy{
+	msg := &pb.Enemy{
 		Id:        enemy.GetId(),
 		PositionX: enemy.GetDirectionX(),
 		PositionY: enemy.GetDirectionY(),
 	}
+	if target, ok := enemy.GetTarget(); ok {
+		setExtUint(msg, EXT_ENEMY_TARGET, uint64(target))
+	}
+	return msg
 }
 
 func handleSpawnEnemyRequest(enemiesToSpawn []*pb.Enemy) {
-	for _, enemyToSpawn := range enemiesToSpawn {
-		enemyId := spawnEnemy(enemyToSpawn)
+	gameLock.Lock()
+	bossRoom := isBossRoom(game.currentRoom)
+	gameLock.Unlock()
+
+	boss, ok := bossConfig()
+	bossRoom = bossRoom && ok
+
+	enemiesLock.Lock()
+	defer enemiesLock.Unlock()
+
+	for i, enemyToSpawn := range enemiesToSpawn {
+		enemyConfig := minionConfig(i)
+		if bossRoom {
+			// boss comes alone, minions are summoned by it during the fight
+			if i > 0 {
+				break
+			}
+			enemyConfig = boss
+		}
+
+		enemyId := spawnEnemy(enemyToSpawn, enemyConfig)
 		spawnedEnemiesIds = append(spawnedEnemiesIds, enemyId)
 	}
 	isSpawned.Store(true)
 }
 
-func spawnEnemy(enemyToSpawn *pb.Enemy) uint32 {
+func spawnEnemy(enemyToSpawn *pb.Enemy, enemyConfig u.EnemyData) uint32 {
 	newEnemyId := enemyIds.getID()
-	enemyConfig := config.EnemyData[0]
-	enemies[newEnemyId] = g.NewEnemy(
+	enemies[newEnemyId] = newEnemyFromConfig(
 		newEnemyId,
 		int(enemyToSpawn.PositionX/SCALLING_FACTOR),
 		int(enemyToSpawn.PositionY/SCALLING_FACTOR),
+		enemyConfig,
+	)
+
+	return newEnemyId
+}
+
+func newEnemyFromConfig(id uint32, x, y int, enemyConfig u.EnemyData) *g.Enemy {
+	enemy := g.NewEnemy(
+		id,
+		x,
+		y,
 		enemyConfig.Type,
 		enemyConfig.Name,
 		enemyConfig.HP,
@@ -494,8 +764,20 @@ func spawnEnemy(enemyToSpawn *pb.Enemy) uint32 {
 		enemyConfig.TextureData,
 		enemyConfig.CollisionData,
 	)
-
-	return newEnemyId
+	if enemyConfig.Targeting != "" {
+		enemy.SetTargeting(enemyConfig.Targeting)
+	}
+	// enemy needs half of its collision box free around the tile it stands on
+	box := enemyConfig.CollisionData
+	radius := float64(max(box.Width, box.Height)) / 2 / SCALLING_FACTOR
+	enemy.SetClearance(g.ClearanceForRadius(radius))
+	if separation := enemyConfig.Separation; separation != nil {
+		enemy.SetSeparation(radius+separation.Spacing, separation.Weight)
+	}
+	if vision := enemyConfig.Vision; vision != nil {
+		enemy.SetVision(vision.Range, vision.Memory)
+	}
+	return enemy
 }
 
 func convertToCollision(obstacle *pb.Obstacle) g.Coordinate {
@@ -506,6 +788,8 @@ func convertToCollision(obstacle *pb.Obstacle) g.Coordinate {
 }
 
 func handleMapUpdate(update *pb.MapPositionsUpdate, conn *net.UDPConn) {
+	enemiesLock.Lock()
+
 	algorithm.Mutex.Lock()
 
 	addPlayers(update.Players)
@@ -515,18 +799,48 @@ func handleMapUpdate(update *pb.MapPositionsUpdate, conn *net.UDPConn) {
 	algorithm.SetEnemies(enemies)
 
 	algorithm.CreateDistancesMap()
-	algorithm.ClearGraph()
+
+	exportDir, export := flowFieldExports.due()
+	var flowFields []g.FlowFieldSnapshot
+	if export {
+		flowFields = algorithm.Snapshots()
+	}
 
 	algorithm.Mutex.Unlock()
 
+	if export {
+		go exportFlowFields(flowFields, exportDir)
+	}
+
+	// slams go first, players hit by them are invulnerable to touches during the same update
+	bossUpdates, summoned, hits := updateBosses()
+	pushed := updateKnockbacks()
+	hits = append(hits, contactHits()...)
+	hits = append(hits, updateProjectiles()...)
+	positions := make(map[uint32]g.Coordinate, len(players))
+	for id, position := range players {
+		positions[id] = position
+	}
+
 	responseMsg := &pb.MovementUpdate{
 		Variant: pb.MovementVariant_MAP_UPDATE,
 	}
 
-	for _, enemy := range enemies {
+	for id, enemy := range enemies {
 		enemyToSend := convertToProtoEnemy(enemy)
+		if pushed[id] {
+			setKnockbackPosition(enemyToSend, enemy)
+		}
 		responseMsg.EnemyPositions = append(responseMsg.EnemyPositions, enemyToSend)
 	}
+	for _, projectile := range projectiles {
+		addExtMessage(responseMsg, EXT_PROJECTILES, convertToProtoProjectile(projectile))
+	}
+
+	enemiesLock.Unlock()
+
+	broadcastBossUpdates(bossUpdates, summoned)
+	broadcastUpdates(applyHits(hits, positions))
 
 	serializedMsg, err := proto.Marshal(responseMsg)
 	if err != nil {
@@ -541,9 +855,11 @@ func handleMapUpdate(update *pb.MapPositionsUpdate, conn *net.UDPConn) {
 
 func addPlayers(playersProto []*pb.Player) {
 	for _, player := range playersProto {
+		// positions in map update come from a single client, so they are not taken for granted
+		x, y := validator.trustedPosition(player.GetId(), player.PositionX, player.PositionY)
 		players[player.GetId()] = g.Coordinate{
-			X: int(player.PositionX / SCALLING_FACTOR),
-			Y: int(player.PositionY / SCALLING_FACTOR),
+			X: int(x / SCALLING_FACTOR),
+			Y: int(y / SCALLING_FACTOR),
 		}
 	}
 }
@@ -551,7 +867,8 @@ func addPlayers(playersProto []*pb.Player) {
 func addEnemies(enemiesProto []*pb.Enemy) {
 	for _, enemy := range enemiesProto {
 		enemyOnBoard := enemies[enemy.GetId()]
-		if enemyOnBoard != nil {
+		// knocked back enemies are moved by the server
+		if enemyOnBoard != nil && !enemyOnBoard.IsKnockedBack() {
 			enemies[enemy.GetId()].SetPosition(int(enemy.PositionX/SCALLING_FACTOR), int(enemy.PositionY/SCALLING_FACTOR))
 		}
 	}
@@ -565,6 +882,22 @@ func main() {
 		return
 	}
 	flag.Parse()
+	chat.loadFilter(config.ChatFilterFile)
+
+	// dungeon of the first game depends on config
+	game = newGame()
+	if *resume != "" {
+		if err = resumeRun(*resume); err != nil {
+			logger.Info("Couldn't resume run", "file", *resume, "error", err)
+			return
+		}
+		log.Printf("Resumed run from: %s\n", *resume)
+	}
+
+	bans = newBanList(config.BanListFile)
+	if err = bans.load(); err != nil {
+		logger.Info("Couldn't load ban list", "file", config.BanListFile, "error", err)
+	}
 
 	if parsedIP := net.ParseIP(*ipString); parsedIP != nil {
 		ip = parsedIP
@@ -573,9 +906,8 @@ func main() {
 	log.Printf("Starting server on: %v\n", ip)
 
 	userCh := make(chan uint32, 32)
-	graphCh := make(chan bool)
-	isSpawned.Store(false)
-	isMapUpdated.Store(false)
+	// holds only the latest state, so the tcp loop never waits for the udp loop
+	graphCh := make(chan bool, 1)
 
 	//mapDimensionsCh <- false
 	sf := NewSingleFlight()
@@ -584,6 +916,8 @@ func main() {
 	go listenTCP()
 	go handleTCP(userCh, graphCh)
 
-	for {
-	}
+	runAdminConsole(os.Stdin, os.Stdout)
+
+	// keep serving when there is no console attached
+	select {}
 }
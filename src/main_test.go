@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	u "server/utils"
+)
+
+// TestMain loads config used by the server, tests run inside src while config lives in the repository root
+func TestMain(m *testing.M) {
+	var err error
+	config, err = u.NewJsonParser().ParseConfig("../config.json")
+	if err != nil {
+		log.Fatalf("couldn't parse config: %v", err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"google.golang.org/protobuf/proto"
+	"math"
+	g "server/game-controllers"
+	"sync"
+)
+
+// roomMap is collision layout uploaded for a room, parsed once into pathfinding grid and movement bounds
+type roomMap struct {
+	hash   string
+	grid   *g.Grid
+	bounds mapBounds
+}
+
+type mapKey struct {
+	roomID int
+	hash   string
+}
+
+// mapRegistry keeps layouts of every room players have visited, so revisited rooms don't need
+// to be parsed again and clients upload layout only when the server doesn't have it
+type mapRegistry struct {
+	lock    sync.Mutex
+	layouts map[mapKey]*roomMap
+	// layout used in the room, the last uploaded one wins
+	current map[int]*roomMap
+}
+
+func newMapRegistry() *mapRegistry {
+	return &mapRegistry{
+		layouts: make(map[mapKey]*roomMap),
+		current: make(map[int]*roomMap),
+	}
+}
+
+func (r *mapRegistry) get(roomID int) (*roomMap, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	m, ok := r.current[roomID]
+	return m, ok
+}
+
+// register makes uploaded layout the current layout of the room, layout is parsed only when it wasn't
+// uploaded before. It reports whether the current layout of the room changed.
+func (r *mapRegistry) register(roomID int, layout []byte) (*roomMap, bool, error) {
+	hash := mapHash(layout)
+	key := mapKey{roomID: roomID, hash: hash}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if current, ok := r.current[roomID]; ok && current.hash == hash {
+		return current, false, nil
+	}
+
+	m, ok := r.layouts[key]
+	if !ok {
+		var err error
+		if m, err = parseRoomMap(layout); err != nil {
+			return nil, false, err
+		}
+		m.hash = hash
+		r.layouts[key] = m
+	}
+	r.current[roomID] = m
+	return m, true, nil
+}
+
+// clear forgets all layouts, room ids are reused by the next dungeon
+func (r *mapRegistry) clear() {
+	r.lock.Lock()
+	clear(r.layouts)
+	clear(r.current)
+	r.lock.Unlock()
+}
+
+func mapHash(layout []byte) string {
+	sum := sha256.Sum256(layout)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseRoomMap builds grid and bounds of the room from decompressed MapDimensionsUpdate
+func parseRoomMap(layout []byte) (*roomMap, error) {
+	var mapDimensionUpdate pb.MapDimensionsUpdate
+	if err := proto.Unmarshal(layout, &mapDimensionUpdate); err != nil {
+		return nil, err
+	}
+	if len(mapDimensionUpdate.Obstacles) == 0 {
+		return nil, fmt.Errorf("map has no obstacles")
+	}
+
+	var maxHeight int32 = 0
+	var maxWidth int32 = 0
+	var minHeight int32 = math.MaxInt32
+	var minWidth int32 = math.MaxInt32
+
+	collisions := make([]g.Coordinate, 0, len(mapDimensionUpdate.Obstacles))
+	for _, obstacle := range mapDimensionUpdate.Obstacles {
+		collisions = append(collisions, convertToCollision(obstacle))
+		maxHeight = max(maxHeight, int32(obstacle.Top))
+		maxWidth = max(maxWidth, int32(obstacle.Left))
+		minHeight = min(minHeight, int32(obstacle.Top))
+		minWidth = min(minWidth, int32(obstacle.Left))
+	}
+
+	return &roomMap{
+		grid: g.NewGrid(
+			int((maxWidth-minWidth)/SCALLING_FACTOR)+1,
+			int((maxHeight-minHeight)/SCALLING_FACTOR)+1,
+			int(minWidth/SCALLING_FACTOR),
+			int(minHeight/SCALLING_FACTOR),
+			collisions,
+		),
+		bounds: mapBounds{
+			minX: float32(minWidth),
+			minY: float32(minHeight),
+			maxX: float32(maxWidth + SCALLING_FACTOR),
+			maxY: float32(maxHeight + SCALLING_FACTOR),
+		},
+	}, nil
+}
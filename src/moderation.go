@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+type banEntries struct {
+	IPs   map[string]string `json:"ips"`
+	Names map[string]string `json:"names"`
+}
+
+// banList keeps banned ip addresses and player names together with ban reasons,
+// every change is written to file, so bans survive server restarts
+type banList struct {
+	lock    sync.Mutex
+	path    string
+	entries banEntries
+}
+
+func newBanList(path string) *banList {
+	return &banList{
+		path: path,
+		entries: banEntries{
+			IPs:   make(map[string]string),
+			Names: make(map[string]string),
+		},
+	}
+}
+
+// load reads bans from file, missing file means that nobody is banned yet
+func (b *banList) load() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := banEntries{}
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	if entries.IPs != nil {
+		b.entries.IPs = entries.IPs
+	}
+	// names are compared without case, list might have been edited by hand
+	for name, reason := range entries.Names {
+		b.entries.Names[banKey(name)] = reason
+	}
+	return nil
+}
+
+// banKey returns name under which ban of player name is stored, names that differ only in case
+// belong to the same player like when checking whether name is taken
+func banKey(name string) string {
+	return strings.ToLower(name)
+}
+
+func (b *banList) save() error {
+	data, err := json.MarshalIndent(b.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, data, 0644)
+}
+
+// check returns reason of ban if ip address or name is banned, empty name is never banned
+func (b *banList) check(ip, name string) (string, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if reason, ok := b.entries.IPs[ip]; ok {
+		return reason, true
+	}
+	if reason, ok := b.entries.Names[banKey(name)]; ok && name != "" {
+		return reason, true
+	}
+	return "", false
+}
+
+func (b *banList) banIP(ip, reason string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.entries.IPs[ip] = reason
+	return b.save()
+}
+
+func (b *banList) banName(name, reason string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.entries.Names[banKey(name)] = reason
+	return b.save()
+}
+
+// unban removes ban of ip address or name, returns false if there was no such ban
+func (b *banList) unban(ipOrName string) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	_, isIP := b.entries.IPs[ipOrName]
+	_, isName := b.entries.Names[banKey(ipOrName)]
+	if !isIP && !isName {
+		return false, nil
+	}
+
+	delete(b.entries.IPs, ipOrName)
+	delete(b.entries.Names, banKey(ipOrName))
+	return true, b.save()
+}
+
+// list returns copy of all bans
+func (b *banList) list() banEntries {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	entries := banEntries{
+		IPs:   make(map[string]string, len(b.entries.IPs)),
+		Names: make(map[string]string, len(b.entries.Names)),
+	}
+	for ip, reason := range b.entries.IPs {
+		entries.IPs[ip] = reason
+	}
+	for name, reason := range b.entries.Names {
+		entries.Names[name] = reason
+	}
+	return entries
+}
+
+// kickQueue collects players that should be disconnected, they are dropped by the tcp loop
+// which owns connections. Kicks are queued only for connected players, so a kick can't
+// hit the next player that gets the same id.
+type kickQueue struct {
+	lock      sync.Mutex
+	reasons   map[uint32]string
+	connected map[uint32]bool
+}
+
+func newKickQueue() *kickQueue {
+	return &kickQueue{
+		reasons:   make(map[uint32]string),
+		connected: make(map[uint32]bool),
+	}
+}
+
+func (k *kickQueue) request(playerID uint32, reason string) {
+	k.lock.Lock()
+	if _, ok := k.reasons[playerID]; !ok && k.connected[playerID] {
+		k.reasons[playerID] = reason
+	}
+	k.lock.Unlock()
+}
+
+func (k *kickQueue) connect(playerID uint32) {
+	k.lock.Lock()
+	k.connected[playerID] = true
+	k.lock.Unlock()
+}
+
+// disconnect forgets player together with its pending kick
+func (k *kickQueue) disconnect(playerID uint32) {
+	k.lock.Lock()
+	delete(k.connected, playerID)
+	delete(k.reasons, playerID)
+	k.lock.Unlock()
+}
+
+// take returns reason of requested kick and forgets about it
+func (k *kickQueue) take(playerID uint32) (string, bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	reason, ok := k.reasons[playerID]
+	delete(k.reasons, playerID)
+	return reason, ok
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	g "server/game-controllers"
+)
+
+const (
+	VIOLATION_OUT_OF_BOUNDS = "out of map bounds"
+	VIOLATION_TOO_FAST      = "moved too fast"
+	VIOLATION_THROUGH_WALL  = "moved through wall"
+)
+
+type trackedPosition struct {
+	x, y float32
+	at   time.Time
+}
+
+type mapBounds struct {
+	minX, minY, maxX, maxY float32
+}
+
+func (b *mapBounds) contains(x, y float32) bool {
+	return x >= b.minX && x <= b.maxX && y >= b.minY && y <= b.maxY
+}
+
+func (b *mapBounds) clamp(x, y float32) (float32, float32) {
+	return min(max(x, b.minX), b.maxX), min(max(y, b.minY), b.maxY)
+}
+
+type movementValidator struct {
+	lock       sync.Mutex
+	positions  map[uint32]trackedPosition
+	violations map[uint32]int
+	bounds     *mapBounds
+}
+
+func newMovementValidator() *movementValidator {
+	return &movementValidator{
+		positions:  make(map[uint32]trackedPosition),
+		violations: make(map[uint32]int),
+	}
+}
+
+// validate checks position reported by player against the last accepted one and returns
+// position that should be passed to other players, reported position is accepted as is
+// only when it is inside map, reachable with player speed and not behind a wall
+func (v *movementValidator) validate(playerID uint32, x, y float32, now time.Time) (float32, float32, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.bounds != nil && !v.bounds.contains(x, y) {
+		v.flag(playerID, VIOLATION_OUT_OF_BOUNDS)
+		x, y = v.bounds.clamp(x, y)
+	}
+
+	last, ok := v.positions[playerID]
+	if !ok {
+		v.positions[playerID] = trackedPosition{x: x, y: y, at: now}
+		return x, y, true
+	}
+
+	elapsed := now.Sub(last.at).Seconds()
+	maxDistance := float64(config.PlayerAcc)*config.MovementSpeedTolerance*elapsed + SCALLING_FACTOR
+	if math.Hypot(float64(x-last.x), float64(y-last.y)) > maxDistance {
+		v.flag(playerID, VIOLATION_TOO_FAST)
+		return last.x, last.y, false
+	}
+
+	algorithm.Mutex.RLock()
+	throughWall := algorithm.CrossesObstacle(toTile(last.x, last.y), toTile(x, y))
+	algorithm.Mutex.RUnlock()
+	if throughWall {
+		v.flag(playerID, VIOLATION_THROUGH_WALL)
+		return last.x, last.y, false
+	}
+
+	v.positions[playerID] = trackedPosition{x: x, y: y, at: now}
+	return x, y, true
+}
+
+// trustedPosition returns last accepted position of player, position reported by somebody else
+// is used only for players that haven't moved yet and is kept inside the map
+func (v *movementValidator) trustedPosition(playerID uint32, x, y float32) (float32, float32) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if last, ok := v.positions[playerID]; ok {
+		return last.x, last.y
+	}
+	if v.bounds != nil {
+		return v.bounds.clamp(x, y)
+	}
+	return x, y
+}
+
+func (v *movementValidator) flag(playerID uint32, reason string) {
+	v.violations[playerID]++
+	logger.Warn("Suspicious player movement", "playerId", playerID, "reason", reason, "violations", v.violations[playerID])
+}
+
+func (v *movementValidator) setBounds(bounds mapBounds) {
+	v.lock.Lock()
+	v.bounds = &bounds
+	v.lock.Unlock()
+}
+
+// resetRoom forgets everything tied to the current room, players are moved to a new place on room change
+func (v *movementValidator) resetRoom() {
+	v.lock.Lock()
+	v.bounds = nil
+	v.positions = make(map[uint32]trackedPosition)
+	v.lock.Unlock()
+}
+
+func (v *movementValidator) removePlayer(playerID uint32) {
+	v.lock.Lock()
+	delete(v.positions, playerID)
+	delete(v.violations, playerID)
+	v.lock.Unlock()
+}
+
+// offenders returns number of flagged movements of every player that was flagged at least once
+func (v *movementValidator) offenders() map[uint32]int {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	offenders := make(map[uint32]int, len(v.violations))
+	for id, count := range v.violations {
+		offenders[id] = count
+	}
+	return offenders
+}
+
+func toTile(x, y float32) g.Coordinate {
+	return g.Coordinate{
+		X: int(x / SCALLING_FACTOR),
+		Y: int(y / SCALLING_FACTOR),
+	}
+}
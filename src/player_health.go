@@ -0,0 +1,255 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	g "server/game-controllers"
+)
+
+type lifeState int
+
+const (
+	PLAYER_ALIVE lifeState = iota
+	// downed player can't move nor attack and dies unless a teammate revives them in time
+	PLAYER_DOWNED
+	// dead player comes back when the party enters another room
+	PLAYER_DEAD
+)
+
+var lifeStateNames = map[lifeState]string{
+	PLAYER_ALIVE:  "alive",
+	PLAYER_DOWNED: "downed",
+	PLAYER_DEAD:   "dead",
+}
+
+// playerHit is damage dealt to a player by an enemy
+type playerHit struct {
+	playerId uint32
+	enemyId  uint32
+	damage   float64
+}
+
+var lastHealthUpdate = time.Now()
+
+// contactHits finds players touched by enemies, collision box of enemy is placed
+// at its position moved by offset from config. enemiesLock has to be locked by the caller.
+func contactHits() []playerHit {
+	hits := make([]playerHit, 0)
+	halfWidth := float32(config.PlayerHitboxSize[0] / 2)
+	halfHeight := float32(config.PlayerHitboxSize[1] / 2)
+
+	for playerId, tile := range players {
+		x, y := validator.trustedPosition(playerId, float32(tile.X*SCALLING_FACTOR), float32(tile.Y*SCALLING_FACTOR))
+
+		for _, enemy := range enemies {
+			box := enemy.GetCollisionData()
+			left := float32(enemy.GetPosition().X*SCALLING_FACTOR) + box.XOffset
+			top := float32(enemy.GetPosition().Y*SCALLING_FACTOR) + box.YOffset
+
+			if x+halfWidth >= left && x-halfWidth <= left+box.Width &&
+				y+halfHeight >= top && y-halfHeight <= top+box.Height {
+				hits = append(hits, playerHit{playerId: playerId, enemyId: enemy.GetId(), damage: enemy.GetDamage()})
+			}
+		}
+	}
+	return hits
+}
+
+// applyHits deals damage to players and advances downed players towards death or revive,
+// it returns updates for every player whose health changed. Positions are tiles players stand on.
+func applyHits(hits []playerHit, positions map[uint32]g.Coordinate) []*pb.StateUpdate {
+	now := time.Now()
+	dt := now.Sub(lastHealthUpdate).Seconds()
+	lastHealthUpdate = now
+
+	gameLock.Lock()
+	defer gameLock.Unlock()
+
+	updates := make([]*pb.StateUpdate, 0)
+	damaged := make(map[uint32]bool)
+	for _, hit := range hits {
+		if update := game.damagePlayer(hit, now); update != nil {
+			updates = append(updates, update)
+			damaged[hit.playerId] = true
+		}
+	}
+	for playerId := range damaged {
+		updates = append(updates, game.players[playerId].statsUpdate(now))
+	}
+	updates = append(updates, game.updateDownedPlayers(positions, dt, now)...)
+
+	if game.isWiped() {
+		if summary := game.endRun(RUN_WIPE, now); summary != nil {
+			updates = append(updates, summary)
+		}
+	}
+	return updates
+}
+
+// damagePlayer lowers health of player unless they are still invulnerable after previous hit,
+// gameLock has to be locked by the caller
+func (g *Game) damagePlayer(hit playerHit, now time.Time) *pb.StateUpdate {
+	// ids in map update come from a client
+	if int(hit.playerId) >= len(g.players) {
+		return nil
+	}
+	player := &g.players[hit.playerId]
+	if !player.registered || player.life != PLAYER_ALIVE || now.Before(player.invulnerableUntil) {
+		return nil
+	}
+
+	hpBefore := player.hp
+	player.hp = max(0, player.hp-hit.damage)
+	player.stats.DamageTaken += hpBefore - player.hp
+	invulnerability := config.InvulnerabilityTimeAfterDMG * config.OneFrameTime
+	player.invulnerableUntil = now.Add(time.Duration(invulnerability * float64(time.Second)))
+
+	if player.hp > 0 {
+		return player.healthUpdate()
+	}
+
+	if g.alivePlayers() == 0 {
+		logger.Info("Last player standing died", "playerId", player.id, "enemyId", hit.enemyId)
+		return g.killPlayer(player, now)
+	}
+
+	player.life = PLAYER_DOWNED
+	player.stats.stopAlive(now)
+	player.downedUntil = now.Add(time.Duration(config.DownedTime * float64(time.Second)))
+	player.reviveProgress = 0
+	logger.Info("Player downed", "playerId", player.id, "enemyId", hit.enemyId)
+	return player.healthUpdate()
+}
+
+// updateDownedPlayers revives downed players that had a living teammate next to them for long enough
+// and kills the ones that weren't revived in time, gameLock has to be locked by the caller
+func (g *Game) updateDownedPlayers(positions map[uint32]g.Coordinate, dt float64, now time.Time) []*pb.StateUpdate {
+	updates := make([]*pb.StateUpdate, 0)
+	for i := range g.players {
+		player := &g.players[i]
+		if !player.registered || player.life != PLAYER_DOWNED {
+			continue
+		}
+
+		if g.alivePlayers() == 0 || now.After(player.downedUntil) {
+			updates = append(updates, g.killPlayer(player, now))
+			continue
+		}
+
+		if !g.isReviverNear(player.id, positions) {
+			player.reviveProgress = 0
+			continue
+		}
+
+		player.reviveProgress += dt
+		if player.reviveProgress >= config.ReviveTime {
+			player.life = PLAYER_ALIVE
+			player.hp = config.MaxCharacterHP * config.ReviveHPRatio
+			player.stats.startAlive(now)
+			logger.Info("Player revived", "playerId", player.id)
+			updates = append(updates, player.healthUpdate())
+		}
+	}
+	return updates
+}
+
+// respawnDeadPlayers brings dead and downed players back with full health when party enters another room,
+// gameLock has to be locked by the caller
+func (g *Game) respawnDeadPlayers() []*pb.StateUpdate {
+	now := time.Now()
+	updates := make([]*pb.StateUpdate, 0)
+	for i := range g.players {
+		player := &g.players[i]
+		if player.registered && player.life != PLAYER_ALIVE {
+			player.life = PLAYER_ALIVE
+			player.hp = config.DefaultCharacterHP
+			player.stats.startAlive(now)
+			updates = append(updates, player.healthUpdate())
+		}
+	}
+	return updates
+}
+
+func (g *Game) killPlayer(player *Player, now time.Time) *pb.StateUpdate {
+	player.life = PLAYER_DEAD
+	player.hp = 0
+	player.stats.stopAlive(now)
+	logger.Info("Player died", "playerId", player.id)
+
+	update := player.healthUpdate()
+	update.Variant = pb.StateVariant_PLAYER_DIED
+	return update
+}
+
+func (g *Game) alivePlayers() int {
+	alive := 0
+	for _, player := range g.players {
+		if player.registered && player.life == PLAYER_ALIVE {
+			alive++
+		}
+	}
+	return alive
+}
+
+// isReviverNear reports whether any living player stands close enough to revive downed player
+func (g *Game) isReviverNear(downedId uint32, positions map[uint32]g.Coordinate) bool {
+	downed, ok := positions[downedId]
+	if !ok {
+		return false
+	}
+
+	for _, player := range g.players {
+		if !player.registered || player.life != PLAYER_ALIVE {
+			continue
+		}
+		tile, ok := positions[player.id]
+		if ok && math.Hypot(float64(tile.X-downed.X), float64(tile.Y-downed.Y)) <= config.ReviveDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// isAlive reports whether player can fight, gameLock has to be locked by the caller
+func (g *Game) isAlive(playerId uint32) bool {
+	if int(playerId) >= len(g.players) {
+		return false
+	}
+	player := g.players[playerId]
+	return player.registered && player.life == PLAYER_ALIVE
+}
+
+func isPlayerAlive(playerId uint32) bool {
+	gameLock.Lock()
+	defer gameLock.Unlock()
+
+	return game.isAlive(playerId)
+}
+
+func (p *Player) healthUpdate() *pb.StateUpdate {
+	protoPlayer := &pb.Player{Id: p.id}
+	setExtFloat(protoPlayer, EXT_PLAYER_HP, float32(p.hp))
+	setExtFloat(protoPlayer, EXT_PLAYER_MAX_HP, float32(config.MaxCharacterHP))
+	setExtString(protoPlayer, EXT_PLAYER_LIFE_STATE, lifeStateNames[p.life])
+
+	return &pb.StateUpdate{
+		Variant: PLAYER_HEALTH,
+		Player:  protoPlayer,
+	}
+}
+
+// broadcastUpdates sends updates to every player
+func broadcastUpdates(updates []*pb.StateUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+
+	connLock.RLock()
+	defer connLock.RUnlock()
+
+	for _, update := range updates {
+		broadcastUpdate(update)
+	}
+}
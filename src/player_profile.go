@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+)
+
+type playerProfile struct {
+	name  string
+	class string
+}
+
+// defaultProfile is profile of player that didn't introduce itself, server gives it default name
+func defaultProfile() playerProfile {
+	profile := playerProfile{}
+	if len(config.PlayerClasses) > 0 {
+		profile.class = config.PlayerClasses[0]
+	}
+	return profile
+}
+
+// takeJoinRequest removes CONNECTED update in which client introduces its player from the series and
+// returns profile from it. Invalid name or class are replaced with default ones.
+func takeJoinRequest(updateSeries *pb.StateUpdateSeries) (playerProfile, bool) {
+	profile := defaultProfile()
+	i := slices.IndexFunc(updateSeries.GetUpdates(), func(update *pb.StateUpdate) bool {
+		return update.Variant == pb.StateVariant_CONNECTED && update.Player != nil
+	})
+	if i < 0 {
+		return profile, false
+	}
+
+	update := updateSeries.Updates[i]
+	updateSeries.Updates = slices.Delete(updateSeries.Updates, i, i+1)
+	if name, ok := getExtString(update.Player, EXT_PLAYER_NAME); ok {
+		profile.name = sanitizeName(name)
+	}
+	if class, ok := getExtString(update.Player, EXT_PLAYER_CLASS); ok && slices.Contains(config.PlayerClasses, class) {
+		profile.class = class
+	}
+	return profile, true
+}
+
+// introducePlayer decides profile of player from the first message it sent. Players join with default profile,
+// so older clients that don't send join request don't wait for anything. Player who sent join request gets
+// chosen name and class, everybody is told about it. It returns false when the player was kicked for its name,
+// connLock has to be read locked by the caller.
+func introducePlayer(updateSeries *pb.StateUpdateSeries, id uint32, conn *net.TCPConn) bool {
+	profile, ok := takeJoinRequest(updateSeries)
+	if !ok {
+		logger.Info("Client joined without join request", "playerId", id)
+	}
+
+	remoteIP := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	if reason, banned := bans.check(remoteIP, profile.name); banned {
+		log.Printf("rejected banned name: %s\n", profile.name)
+		kicks.request(id, reason)
+		return false
+	}
+
+	gameLock.Lock()
+	if profile.name != "" && game.isNameTaken(profile.name) {
+		gameLock.Unlock()
+		kicks.request(id, fmt.Sprintf("Name %s is already taken", profile.name))
+		return false
+	}
+	var profileUpdate *pb.StateUpdate
+	if ok {
+		profileUpdate = game.introduce(id, profile)
+	}
+	name := game.playerName(id)
+	gameLock.Unlock()
+	// default names are given again to other players, so unnamed players are muted only by address
+	chat.addPlayer(id, remoteIP, profile.name)
+
+	if profileUpdate != nil {
+		broadcastUpdate(profileUpdate)
+	}
+	joinedMsg := systemMessage(fmt.Sprintf("%s joined", name))
+	for otherID, c := range tcpConns {
+		if otherID != id {
+			c.Write(joinedMsg)
+		}
+	}
+	return true
+}
+
+// defaultNamePattern matches names given by the server to players that didn't choose any
+var defaultNamePattern = regexp.MustCompile(`(?i)^player\s*\d+$`)
+
+// sanitizeName removes control characters from name, returns empty string if nothing usable is left,
+// name is too long or it looks like a default name, which could be given to somebody else later
+func sanitizeName(name string) string {
+	name = stripControlCharacters(name)
+	if utf8.RuneCountInString(name) > config.MaxPlayerNameLength || defaultNamePattern.MatchString(name) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+)
+
+// tcpPair returns server side of a loopback connection, client side is closed when the test ends
+func tcpPair(t *testing.T) *net.TCPConn {
+	t.Helper()
+
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	defer listener.Close()
+
+	client, err := net.DialTCP("tcp", nil, listener.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("couldn't dial: %v", err)
+	}
+	server, err := listener.AcceptTCP()
+	if err != nil {
+		t.Fatalf("couldn't accept: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server
+}
+
+func joinRequest(name, class string) *pb.StateUpdate {
+	player := &pb.Player{}
+	setExtString(player, EXT_PLAYER_NAME, name)
+	setExtString(player, EXT_PLAYER_CLASS, class)
+	return &pb.StateUpdate{Variant: pb.StateVariant_CONNECTED, Player: player}
+}
+
+func TestIntroducePlayerFromFirstMessage(t *testing.T) {
+	class := config.PlayerClasses[len(config.PlayerClasses)-1]
+	chatUpdate := &pb.StateUpdate{Variant: CHAT_MESSAGE}
+
+	tests := []struct {
+		name       string
+		updates    []*pb.StateUpdate
+		playerName string
+		class      string
+		left       int
+		kicked     bool
+	}{
+		{"join request", []*pb.StateUpdate{joinRequest("Alice", class), chatUpdate}, "Alice", class, 1, false},
+		{"older client", []*pb.StateUpdate{chatUpdate}, "", config.PlayerClasses[0], 1, false},
+		{"default looking name", []*pb.StateUpdate{joinRequest("player 7", "wizard?")}, "", config.PlayerClasses[0], 0, false},
+		{"banned name", []*pb.StateUpdate{joinRequest("Mallory", class)}, "", config.PlayerClasses[0], 0, true},
+		{"taken name", []*pb.StateUpdate{joinRequest("bob", class)}, "", config.PlayerClasses[0], 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game = newGame()
+			tcpConns = make(map[uint32]*net.TCPConn)
+			kicks = newKickQueue()
+			chat = newChatModerator()
+			bans = newBanList(filepath.Join(t.TempDir(), "bans.json"))
+			bans.banName("mallory", "cheating")
+			connectTestPlayer(t, "Bob")
+
+			id := connectTestPlayer(t, "")
+			conn := tcpConns[id]
+			defaultName := game.playerName(id)
+
+			series := &pb.StateUpdateSeries{Updates: test.updates}
+			introduced := introducePlayer(series, id, conn)
+			if _, kicked := kicks.take(id); introduced == test.kicked || kicked != test.kicked {
+				t.Fatalf("player introduced: %v, kicked: %v", introduced, kicked)
+			}
+			if len(series.GetUpdates()) != test.left {
+				t.Errorf("%d updates left to handle, expected %d", len(series.GetUpdates()), test.left)
+			}
+
+			expectedName := test.playerName
+			if expectedName == "" {
+				expectedName = defaultName
+			}
+			if player := game.players[id]; player.name != expectedName || player.class != test.class {
+				t.Errorf("player is %s (%s), expected %s (%s)", player.name, player.class, expectedName, test.class)
+			}
+		})
+	}
+}
+
+func TestIntroducedPlayerGetsSavedProgressBack(t *testing.T) {
+	game = newGame()
+	tcpConns = make(map[uint32]*net.TCPConn)
+	kicks = newKickQueue()
+	bans = newBanList(filepath.Join(t.TempDir(), "bans.json"))
+
+	saved := []Item{{id: 900, r: 7, variant: pb.ItemType_ARMOUR}}
+	game.savedInventories["Alice"] = saved
+	game.savedProgress["Alice"] = playerSnapshot{HP: 42, Life: lifeStateNames[PLAYER_ALIVE], Stats: playerStats{XP: 120, Level: 3}}
+
+	id := connectTestPlayer(t, "")
+	conn := tcpConns[id]
+
+	series := &pb.StateUpdateSeries{Updates: []*pb.StateUpdate{joinRequest("Alice", config.PlayerClasses[0])}}
+	if !introducePlayer(series, id, conn) {
+		t.Fatalf("player wasn't introduced")
+	}
+
+	player := game.players[id]
+	if len(player.items) != 1 || player.items[0] != saved[0] {
+		t.Errorf("player has items %v, expected saved %v", player.items, saved)
+	}
+	if player.hp != 42 || player.stats.XP != 120 || player.stats.Level != 3 {
+		t.Errorf("player has %v hp, %v xp and level %d, expected saved progress", player.hp, player.stats.XP, player.stats.Level)
+	}
+	if _, ok := game.savedProgress["Alice"]; ok {
+		t.Errorf("saved progress wasn't taken")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+)
+
+const (
+	RUN_WIPE    = "wipe"
+	RUN_VICTORY = "victory"
+)
+
+// playerStats is progress of player during the run, it's sent to clients as JSON
+type playerStats struct {
+	Kills          map[string]int `json:"kills"`
+	DamageDealt    float64        `json:"damageDealt"`
+	DamageTaken    float64        `json:"damageTaken"`
+	ItemsCollected int            `json:"itemsCollected"`
+	RoomsCleared   int            `json:"roomsCleared"`
+	TimeAlive      float64        `json:"timeAlive"`
+	XP             float64        `json:"xp"`
+	Level          int            `json:"level"`
+
+	// zero while player is downed or dead
+	aliveSince time.Time
+}
+
+type playerSummary struct {
+	ID    uint32      `json:"id"`
+	Name  string      `json:"name"`
+	Class string      `json:"class"`
+	Stats playerStats `json:"stats"`
+}
+
+// runSummary is scoreboard shown to players when the run ends
+type runSummary struct {
+	Outcome  string          `json:"outcome"`
+	Duration float64         `json:"duration"`
+	Depth    int             `json:"depth"`
+	Players  []playerSummary `json:"players"`
+}
+
+func newPlayerStats(now time.Time) playerStats {
+	return playerStats{
+		Kills:      make(map[string]int),
+		Level:      1,
+		aliveSince: now,
+	}
+}
+
+// levelForXP returns level reached with given XP, curve in config lists XP needed for every next level
+func levelForXP(xp float64) int {
+	level := 1
+	for _, threshold := range config.XPCurve {
+		if xp >= threshold {
+			level++
+		}
+	}
+	return level
+}
+
+// addXP reports whether player reached new level
+func (s *playerStats) addXP(xp float64) bool {
+	s.XP += xp
+	level := levelForXP(s.XP)
+	levelUp := level > s.Level
+	s.Level = level
+	return levelUp
+}
+
+func (s *playerStats) startAlive(now time.Time) {
+	if s.aliveSince.IsZero() {
+		s.aliveSince = now
+	}
+}
+
+func (s *playerStats) stopAlive(now time.Time) {
+	if !s.aliveSince.IsZero() {
+		s.TimeAlive += now.Sub(s.aliveSince).Seconds()
+		s.aliveSince = time.Time{}
+	}
+}
+
+// current returns stats with time alive counted up to now
+func (s *playerStats) current(now time.Time) playerStats {
+	stats := *s
+	if !s.aliveSince.IsZero() {
+		stats.TimeAlive += now.Sub(s.aliveSince).Seconds()
+	}
+	return stats
+}
+
+func (p *Player) statsUpdate(now time.Time) *pb.StateUpdate {
+	protoPlayer := &pb.Player{Id: p.id}
+	setExtFloat(protoPlayer, EXT_PLAYER_XP, float32(p.stats.XP))
+	setExtUint(protoPlayer, EXT_PLAYER_LEVEL, uint64(p.stats.Level))
+
+	stats, err := json.Marshal(p.stats.current(now))
+	if err != nil {
+		logger.Info("Failed to serialize player stats", "playerId", p.id, "error", err)
+	} else {
+		setExtString(protoPlayer, EXT_PLAYER_STATS, string(stats))
+	}
+
+	return &pb.StateUpdate{
+		Variant: PLAYER_STATS,
+		Player:  protoPlayer,
+	}
+}
+
+// recordHit credits player with damage dealt to enemy and with its kill,
+// gameLock has to be locked by the caller
+func (g *Game) recordHit(playerId uint32, enemyName string, damage float64, killed bool) *pb.StateUpdate {
+	if int(playerId) >= len(g.players) || !g.players[playerId].registered {
+		return nil
+	}
+	player := &g.players[playerId]
+
+	player.stats.DamageDealt += damage
+	if killed {
+		player.stats.Kills[enemyName]++
+		if enemyConfig, ok := enemyConfigByName(enemyName); ok && player.stats.addXP(enemyConfig.XP) {
+			logger.Info("Player levelled up", "playerId", playerId, "level", player.stats.Level)
+		}
+	}
+	return player.statsUpdate(time.Now())
+}
+
+// recordItemCollected counts item picked up from the floor, gameLock has to be locked by the caller
+func (g *Game) recordItemCollected(playerId uint32) *pb.StateUpdate {
+	if int(playerId) >= len(g.players) || !g.players[playerId].registered {
+		return nil
+	}
+	player := &g.players[playerId]
+
+	player.stats.ItemsCollected++
+	return player.statsUpdate(time.Now())
+}
+
+// clearCurrentRoom marks current room as cleared and rewards every player, room is rewarded only once.
+// It's called only when the server sees the last enemy of the room die, so clearing the boss room
+// ends the run. gameLock has to be locked by the caller.
+func (g *Game) clearCurrentRoom() []*pb.StateUpdate {
+	state := g.currentRoomState()
+	if state.cleared {
+		return nil
+	}
+	state.cleared = true
+
+	now := time.Now()
+	updates := make([]*pb.StateUpdate, 0)
+	for i := range g.players {
+		player := &g.players[i]
+		if !player.registered {
+			continue
+		}
+
+		player.stats.RoomsCleared++
+		if player.stats.addXP(config.RoomClearXP) {
+			logger.Info("Player levelled up", "playerId", player.id, "level", player.stats.Level)
+		}
+		updates = append(updates, player.statsUpdate(now))
+	}
+
+	if isBossRoom(g.currentRoom) {
+		if summary := g.endRun(RUN_VICTORY, now); summary != nil {
+			updates = append(updates, summary)
+		}
+	}
+	return updates
+}
+
+// isWiped reports whether every player in the party is dead
+func (g *Game) isWiped() bool {
+	registered := 0
+	for _, player := range g.players {
+		if player.registered {
+			registered++
+			if player.life != PLAYER_DEAD {
+				return false
+			}
+		}
+	}
+	return registered > 0
+}
+
+// endRun creates scoreboard of the run, it's created only once per run. gameLock has to be locked by the caller.
+func (g *Game) endRun(outcome string, now time.Time) *pb.StateUpdate {
+	if g.runOver {
+		return nil
+	}
+	g.runOver = true
+
+	summary := runSummary{
+		Outcome:  outcome,
+		Duration: now.Sub(g.startedAt).Seconds(),
+		Depth:    g.currentRoom.Depth,
+		Players:  make([]playerSummary, 0, MAX_PLAYERS),
+	}
+	for _, player := range g.players {
+		if player.registered {
+			summary.Players = append(summary.Players, playerSummary{
+				ID:    player.id,
+				Name:  player.name,
+				Class: player.class,
+				Stats: player.stats.current(now),
+			})
+		}
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		logger.Info("Failed to serialize run summary", "error", err)
+		return nil
+	}
+	logger.Info("Run is over", "outcome", outcome, "depth", summary.Depth, "duration", summary.Duration)
+
+	update := &pb.StateUpdate{Variant: RUN_SUMMARY}
+	setExtString(update, EXT_RUN_SUMMARY, string(data))
+	return update
+}
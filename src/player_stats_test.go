@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	g "server/game-controllers"
+)
+
+func TestLevelForXP(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.XPCurve = []float64{100, 250, 450}
+
+	tests := []struct {
+		xp    float64
+		level int
+	}{
+		{0, 1},
+		{99, 1},
+		{100, 2},
+		{249.9, 2},
+		{250, 3},
+		{450, 4},
+		{1e6, 4},
+	}
+
+	for _, test := range tests {
+		if level := levelForXP(test.xp); level != test.level {
+			t.Errorf("%v xp gives level %d, expected %d", test.xp, level, test.level)
+		}
+	}
+}
+
+func TestAddXPReportsLevelUp(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.XPCurve = []float64{100, 250}
+
+	stats := newPlayerStats(time.Now())
+	steps := []struct {
+		xp      float64
+		levelUp bool
+		level   int
+	}{
+		{60, false, 1},
+		{60, true, 2},
+		{300, true, 3},
+		{300, false, 3},
+	}
+
+	for i, step := range steps {
+		if levelUp := stats.addXP(step.xp); levelUp != step.levelUp || stats.Level != step.level {
+			t.Errorf("step %d: level up %v to level %d, expected %v to level %d", i, levelUp, stats.Level, step.levelUp, step.level)
+		}
+	}
+}
+
+func TestRecordHitCreditsKills(t *testing.T) {
+	game = newGame()
+	slime := config.EnemyData[0]
+	id := game.createInitialInfo(defaultProfile()).Player.Id
+
+	game.recordHit(id, slime.Name, 4, false)
+	game.recordHit(id, slime.Name, 6, true)
+	if update := game.recordHit(99, slime.Name, 6, true); update != nil {
+		t.Errorf("hit of player that isn't connected was recorded")
+	}
+
+	stats := game.players[id].stats
+	if stats.DamageDealt != 10 || stats.Kills[slime.Name] != 1 || stats.XP != slime.XP {
+		t.Errorf("player has %v damage, %d kills and %v xp, expected 10, 1 and %v", stats.DamageDealt, stats.Kills[slime.Name], stats.XP, slime.XP)
+	}
+}
+
+func TestClearCurrentRoom(t *testing.T) {
+	tests := []struct {
+		name    string
+		room    g.RoomType
+		victory bool
+	}{
+		{"combat room", g.COMBAT_ROOM, false},
+		{"boss room", g.BOSS_ROOM, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game = newGame()
+			for _, room := range game.dungeon.GetRooms() {
+				if room.Type == test.room {
+					game.enterRoom(room)
+				}
+			}
+			first := game.createInitialInfo(defaultProfile()).Player.Id
+			second := game.createInitialInfo(defaultProfile()).Player.Id
+
+			updates := game.clearCurrentRoom()
+			summary := runSummaryFrom(t, updates)
+			if (summary != nil) != test.victory || summary != nil && summary.Outcome != RUN_VICTORY {
+				t.Errorf("clearing room ended the run with %+v", summary)
+			}
+			for _, id := range []uint32{first, second} {
+				if stats := game.players[id].stats; stats.RoomsCleared != 1 || stats.XP != config.RoomClearXP {
+					t.Errorf("player %d has %d cleared rooms and %v xp", id, stats.RoomsCleared, stats.XP)
+				}
+			}
+
+			if updates := game.clearCurrentRoom(); len(updates) != 0 {
+				t.Errorf("room was rewarded twice")
+			}
+		})
+	}
+}
+
+func TestRunEndsOnlyOnce(t *testing.T) {
+	game = newGame()
+	game.createInitialInfo(playerProfile{name: "Alice", class: "Knight"})
+
+	summary := runSummaryFrom(t, []*pb.StateUpdate{game.endRun(RUN_WIPE, time.Now())})
+	if summary == nil || summary.Outcome != RUN_WIPE || len(summary.Players) != 1 || summary.Players[0].Name != "Alice" {
+		t.Fatalf("run ended with %+v", summary)
+	}
+	if update := game.endRun(RUN_VICTORY, time.Now()); update != nil {
+		t.Errorf("run ended twice")
+	}
+}
+
+func TestIsWiped(t *testing.T) {
+	tests := []struct {
+		name  string
+		lives []lifeState
+		wiped bool
+	}{
+		{"nobody connected", nil, false},
+		{"everybody dead", []lifeState{PLAYER_DEAD, PLAYER_DEAD}, true},
+		{"somebody downed", []lifeState{PLAYER_DEAD, PLAYER_DOWNED}, false},
+		{"somebody alive", []lifeState{PLAYER_ALIVE, PLAYER_DEAD}, false},
+	}
+
+	for _, test := range tests {
+		game = newGame()
+		for _, life := range test.lives {
+			id := game.createInitialInfo(defaultProfile()).Player.Id
+			game.players[id].life = life
+		}
+		if wiped := game.isWiped(); wiped != test.wiped {
+			t.Errorf("%s: party wiped: %v, expected %v", test.name, wiped, test.wiped)
+		}
+	}
+}
+
+// runSummaryFrom returns summary sent in RUN_SUMMARY update, nil when there is none
+func runSummaryFrom(t *testing.T, updates []*pb.StateUpdate) *runSummary {
+	t.Helper()
+
+	for _, update := range updates {
+		if update == nil || update.Variant != RUN_SUMMARY {
+			continue
+		}
+		data, ok := getExtString(update, EXT_RUN_SUMMARY)
+		if !ok {
+			t.Fatalf("run summary update has no summary")
+		}
+		summary := &runSummary{}
+		if err := json.Unmarshal([]byte(data), summary); err != nil {
+			t.Fatalf("couldn't parse run summary: %v", err)
+		}
+		return summary
+	}
+	return nil
+}
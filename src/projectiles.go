@@ -0,0 +1,111 @@
+package main
+
+import (
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"github.com/ungerik/go3d/vec2"
+	g "server/game-controllers"
+)
+
+const (
+	PROJECTILE_TYPE   = "Projectile"
+	PROJECTILE_MIN_ID = ITEM_MAX_ID + 1
+	PROJECTILE_MAX_ID = PROJECTILE_MIN_ID + 500
+)
+
+var (
+	projectiles          = make(map[uint32]*g.Projectile)
+	projectileIds        = newIDPool(PROJECTILE_MIN_ID, PROJECTILE_MAX_ID)
+	rangedCooldowns      = make(map[uint32]float64)
+	lastProjectileUpdate = time.Now()
+)
+
+// updateProjectiles lets ranged enemies shoot at players they can see and moves projectiles already in the air.
+// enemiesLock has to be locked by the caller.
+func updateProjectiles() []playerHit {
+	now := time.Now()
+	dt := now.Sub(lastProjectileUpdate).Seconds()
+	lastProjectileUpdate = now
+
+	algorithm.Mutex.RLock()
+	defer algorithm.Mutex.RUnlock()
+
+	for id := range rangedCooldowns {
+		if _, ok := enemies[id]; !ok {
+			delete(rangedCooldowns, id)
+		}
+	}
+	for id, enemy := range enemies {
+		shootAtPlayers(id, enemy, dt)
+	}
+
+	hits := make([]playerHit, 0)
+	for id, projectile := range projectiles {
+		playerId, hit, alive := projectile.Update(dt, algorithm, players)
+		if hit {
+			hits = append(hits, playerHit{
+				playerId: playerId,
+				enemyId:  projectile.GetOwnerId(),
+				damage:   projectile.GetDamage(),
+			})
+		}
+		if !alive {
+			delete(projectiles, id)
+			projectileIds.returnID(id)
+		}
+	}
+	return hits
+}
+
+// shootAtPlayers makes ranged enemy stand still while its target is in range and sight,
+// it shoots whenever its cooldown allows
+func shootAtPlayers(id uint32, enemy *g.Enemy, dt float64) {
+	enemyConfig, ok := enemyConfigByName(enemy.GetName())
+	if !ok || enemyConfig.Ranged == nil {
+		return
+	}
+	ranged := enemyConfig.Ranged
+
+	rangedCooldowns[id] -= dt
+	target, distance, ok := enemy.TargetPosition(players)
+	if !ok || distance > ranged.Range || algorithm.CrossesObstacle(enemy.GetPosition(), target) {
+		return
+	}
+
+	enemy.SetDirection(vec2.T{0, 0})
+	if rangedCooldowns[id] > 0 {
+		return
+	}
+
+	projectileId := projectileIds.getID()
+	projectiles[projectileId] = g.NewProjectile(projectileId, enemy, target, *ranged)
+	rangedCooldowns[id] = ranged.Cooldown
+}
+
+// clearProjectiles removes projectiles left in the room, enemiesLock has to be locked by the caller
+func clearProjectiles() {
+	for id := range projectiles {
+		projectileIds.returnID(id)
+	}
+	projectiles = make(map[uint32]*g.Projectile)
+	rangedCooldowns = make(map[uint32]float64)
+}
+
+func convertToProtoProjectile(projectile *g.Projectile) *pb.Enemy {
+	x, y := projectile.GetPosition()
+	velocity := projectile.GetVelocity()
+
+	msg := &pb.Enemy{
+		Id:        projectile.GetId(),
+		PositionX: float32(x) * SCALLING_FACTOR,
+		PositionY: float32(y) * SCALLING_FACTOR,
+		Type:      PROJECTILE_TYPE,
+		Name:      projectile.GetName(),
+		Damage:    projectile.GetDamage(),
+	}
+	setExtUint(msg, EXT_PROJECTILE_OWNER, uint64(projectile.GetOwnerId()))
+	setExtFloat(msg, EXT_PROJECTILE_VELOCITY_X, velocity[0]*SCALLING_FACTOR)
+	setExtFloat(msg, EXT_PROJECTILE_VELOCITY_Y, velocity[1]*SCALLING_FACTOR)
+	return msg
+}
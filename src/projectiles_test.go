@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	g "server/game-controllers"
+)
+
+// testRoom makes algorithm use size x size room surrounded by walls with extra walls inside
+func testRoom(size int, walls ...g.Coordinate) {
+	collisions := append([]g.Coordinate{}, walls...)
+	for i := 0; i < size; i++ {
+		collisions = append(collisions,
+			g.Coordinate{X: i, Y: 0}, g.Coordinate{X: i, Y: size - 1},
+			g.Coordinate{X: 0, Y: i}, g.Coordinate{X: size - 1, Y: i},
+		)
+	}
+	algorithm = g.NewAIAlgorithm()
+	algorithm.SetGrid(g.NewGrid(size, size, 0, 0, collisions))
+}
+
+func TestRangedEnemiesShootPlayersInSight(t *testing.T) {
+	archer, ok := enemyConfigByName("Archer")
+	if !ok || archer.Ranged == nil {
+		t.Fatalf("config has no ranged Archer")
+	}
+
+	tests := []struct {
+		name   string
+		walls  []g.Coordinate
+		player g.Coordinate
+		shots  int
+	}{
+		{"in range and sight", nil, g.Coordinate{X: 10, Y: 5}, 1},
+		{"out of range", nil, g.Coordinate{X: 5 + int(archer.Ranged.Range) + 2, Y: 5}, 0},
+		{"behind wall", []g.Coordinate{{X: 7, Y: 5}}, g.Coordinate{X: 10, Y: 5}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testRoom(20, test.walls...)
+			clearProjectiles()
+			enemies = map[uint32]*g.Enemy{1: newEnemyFromConfig(1, 5, 5, archer)}
+			players = map[uint32]g.Coordinate{7: test.player}
+
+			// the first shot comes right away, the next one only after cooldown
+			lastProjectileUpdate = time.Now()
+			updateProjectiles()
+			lastProjectileUpdate = time.Now().Add(-time.Duration(archer.Ranged.Cooldown / 4 * float64(time.Second)))
+			updateProjectiles()
+
+			if len(projectiles) != test.shots {
+				t.Errorf("archer shot %d times, expected %d", len(projectiles), test.shots)
+			}
+			if test.shots > 0 && (enemies[1].GetDirectionX() != 0 || enemies[1].GetDirectionY() != 0) {
+				t.Errorf("shooting archer keeps moving")
+			}
+		})
+	}
+}
+
+func TestProjectileHitsArePlayerHits(t *testing.T) {
+	archer, _ := enemyConfigByName("Archer")
+	testRoom(20)
+	clearProjectiles()
+	enemies = map[uint32]*g.Enemy{1: newEnemyFromConfig(1, 5, 5, archer)}
+	players = map[uint32]g.Coordinate{7: {X: 8, Y: 5}}
+
+	lastProjectileUpdate = time.Now()
+	updateProjectiles()
+	lastProjectileUpdate = time.Now().Add(-time.Second)
+	hits := updateProjectiles()
+
+	if len(hits) != 1 || hits[0].playerId != 7 || hits[0].enemyId != 1 || hits[0].damage != archer.Ranged.Damage {
+		t.Fatalf("got hits %+v, expected archer hitting player 7 for %v", hits, archer.Ranged.Damage)
+	}
+	if len(projectiles) != 0 {
+		t.Errorf("projectile that hit player still flies")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"math"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// State variants and fields which are not part of qlp-proto-bindings yet. Fields are sent as
+// unknown fields of existing messages, so clients built against older bindings simply skip them.
+const (
+	CHAT_MESSAGE         pb.StateVariant = 100
+	ROOM_TRANSITION_VOTE pb.StateVariant = 101 // update without room means that the vote was cancelled
+	ITEM_DROPPED         pb.StateVariant = 102
+	ROOM_STATE           pb.StateVariant = 103
+	BOSS_STATE           pb.StateVariant = 104
+	BOSS_ATTACK          pb.StateVariant = 105
+	PLAYER_HEALTH        pb.StateVariant = 106
+	PLAYER_STATS         pb.StateVariant = 107
+	RUN_SUMMARY          pb.StateVariant = 108
+	PLAYER_PROFILE       pb.StateVariant = 109 // player introduced itself after joining
+)
+
+const (
+	// StateUpdate fields
+	EXT_CHAT_TEXT    protowire.Number = 100
+	EXT_CHAT_EMOTE   protowire.Number = 101
+	EXT_REASON       protowire.Number = 102
+	EXT_VOTES_NEEDED protowire.Number = 103
+	EXT_FLOOR_ITEMS  protowire.Number = 104
+	EXT_ROOM_CLEARED protowire.Number = 105
+	EXT_CHEST_OPENED protowire.Number = 106
+
+	EXT_BOSS_MAX_HP        protowire.Number = 107
+	EXT_BOSS_PHASE         protowire.Number = 108
+	EXT_BOSS_ATTACK        protowire.Number = 109
+	EXT_BOSS_ATTACK_STAGE  protowire.Number = 110
+	EXT_BOSS_TARGET_X      protowire.Number = 111
+	EXT_BOSS_TARGET_Y      protowire.Number = 112
+	EXT_BOSS_ATTACK_RADIUS protowire.Number = 113
+	EXT_BOSS_ATTACK_DAMAGE protowire.Number = 114
+
+	EXT_RUN_SUMMARY protowire.Number = 115
+
+	// hash of room layout known to the server, sent in ROOM_STATE
+	EXT_MAP_HASH protowire.Number = 116
+
+	// speed multiplier of boss charge, its direction is sent as enemy direction
+	EXT_BOSS_ATTACK_SPEED protowire.Number = 117
+
+	// Player fields
+	EXT_PLAYER_NAME       protowire.Number = 100
+	EXT_PLAYER_CLASS      protowire.Number = 101
+	EXT_PLAYER_HP         protowire.Number = 102
+	EXT_PLAYER_MAX_HP     protowire.Number = 103
+	EXT_PLAYER_LIFE_STATE protowire.Number = 104
+	EXT_PLAYER_XP         protowire.Number = 105
+	EXT_PLAYER_LEVEL      protowire.Number = 106
+	EXT_PLAYER_STATS      protowire.Number = 107
+
+	// Room fields
+	EXT_ROOM_ID    protowire.Number = 100
+	EXT_ROOM_DEPTH protowire.Number = 101
+	EXT_ROOM_TYPE  protowire.Number = 102
+	EXT_ROOM_DOORS protowire.Number = 103
+
+	// Item fields
+	EXT_ITEM_POSITION_X protowire.Number = 100
+	EXT_ITEM_POSITION_Y protowire.Number = 101
+
+	// InitialInfo fields
+	EXT_DUNGEON_ROOMS protowire.Number = 100
+	EXT_CURRENT_ROOM  protowire.Number = 101
+
+	// MovementUpdate fields
+	EXT_PROJECTILES protowire.Number = 100
+
+	// Enemy fields, projectiles are sent as enemies of PROJECTILE_TYPE
+	EXT_PROJECTILE_OWNER      protowire.Number = 100
+	EXT_PROJECTILE_VELOCITY_X protowire.Number = 101
+	EXT_PROJECTILE_VELOCITY_Y protowire.Number = 102
+	// position of enemy moved by server, it replaces position simulated by the client
+	EXT_ENEMY_POSITION_X protowire.Number = 103
+	EXT_ENEMY_POSITION_Y protowire.Number = 104
+	// player the enemy chases
+	EXT_ENEMY_TARGET protowire.Number = 105
+)
+
+var extStateVariantNames = map[pb.StateVariant]string{
+	CHAT_MESSAGE:         "CHAT_MESSAGE",
+	ROOM_TRANSITION_VOTE: "ROOM_TRANSITION_VOTE",
+	ITEM_DROPPED:         "ITEM_DROPPED",
+	ROOM_STATE:           "ROOM_STATE",
+	BOSS_STATE:           "BOSS_STATE",
+	BOSS_ATTACK:          "BOSS_ATTACK",
+	PLAYER_HEALTH:        "PLAYER_HEALTH",
+	PLAYER_STATS:         "PLAYER_STATS",
+	RUN_SUMMARY:          "RUN_SUMMARY",
+	PLAYER_PROFILE:       "PLAYER_PROFILE",
+}
+
+// stateVariantName returns name of variant, including the ones defined by the server
+func stateVariantName(variant pb.StateVariant) string {
+	if name, ok := extStateVariantNames[variant]; ok {
+		return name
+	}
+	return variant.String()
+}
+
+func setExtString(msg proto.Message, field protowire.Number, value string) {
+	setExtField(msg, field, protowire.BytesType, protowire.AppendString(nil, value))
+}
+
+func getExtString(msg proto.Message, field protowire.Number) (string, bool) {
+	value, ok := getExtField(msg, field, protowire.BytesType)
+	if !ok {
+		return "", false
+	}
+
+	str, n := protowire.ConsumeString(value)
+	return str, n >= 0
+}
+
+func setExtUint(msg proto.Message, field protowire.Number, value uint64) {
+	setExtField(msg, field, protowire.VarintType, protowire.AppendVarint(nil, value))
+}
+
+func getExtUint(msg proto.Message, field protowire.Number) (uint64, bool) {
+	value, ok := getExtField(msg, field, protowire.VarintType)
+	if !ok {
+		return 0, false
+	}
+
+	v, n := protowire.ConsumeVarint(value)
+	return v, n >= 0
+}
+
+func setExtBool(msg proto.Message, field protowire.Number, value bool) {
+	setExtUint(msg, field, protowire.EncodeBool(value))
+}
+
+func setExtFloat(msg proto.Message, field protowire.Number, value float32) {
+	setExtField(msg, field, protowire.Fixed32Type, protowire.AppendFixed32(nil, math.Float32bits(value)))
+}
+
+// setExtUints sets repeated field as packed list of varints
+func setExtUints(msg proto.Message, field protowire.Number, values []uint64) {
+	packed := make([]byte, 0, len(values))
+	for _, value := range values {
+		packed = protowire.AppendVarint(packed, value)
+	}
+	setExtField(msg, field, protowire.BytesType, protowire.AppendBytes(nil, packed))
+}
+
+// addExtMessage appends message to repeated field
+func addExtMessage(msg proto.Message, field protowire.Number, value proto.Message) {
+	serialized, err := proto.Marshal(value)
+	if err != nil {
+		logger.Info("Failed to serialize extension message", "error", err)
+		return
+	}
+
+	m := msg.ProtoReflect()
+	unknown := protowire.AppendTag(m.GetUnknown(), field, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, serialized)
+	m.SetUnknown(unknown)
+}
+
+// setExtMessage replaces value of field with message
+func setExtMessage(msg proto.Message, field protowire.Number, value proto.Message) {
+	serialized, err := proto.Marshal(value)
+	if err != nil {
+		logger.Info("Failed to serialize extension message", "error", err)
+		return
+	}
+	setExtField(msg, field, protowire.BytesType, protowire.AppendBytes(nil, serialized))
+}
+
+// setExtField replaces value of unknown field, value has to be already encoded with the given wire type
+func setExtField(msg proto.Message, field protowire.Number, typ protowire.Type, value []byte) {
+	m := msg.ProtoReflect()
+
+	unknown := removeExtField(m.GetUnknown(), field)
+	unknown = protowire.AppendTag(unknown, field, typ)
+	unknown = append(unknown, value...)
+	m.SetUnknown(unknown)
+}
+
+// getExtField returns encoded value of the last occurrence of unknown field
+func getExtField(msg proto.Message, field protowire.Number, typ protowire.Type) ([]byte, bool) {
+	unknown := msg.ProtoReflect().GetUnknown()
+
+	var found []byte
+	for len(unknown) > 0 {
+		num, fieldType, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return nil, false
+		}
+		unknown = unknown[n:]
+
+		m := protowire.ConsumeFieldValue(num, fieldType, unknown)
+		if m < 0 {
+			return nil, false
+		}
+		if num == field && fieldType == typ {
+			found = unknown[:m]
+		}
+		unknown = unknown[m:]
+	}
+	return found, found != nil
+}
+
+func removeExtField(unknown []byte, field protowire.Number) []byte {
+	result := make([]byte, 0, len(unknown))
+	for len(unknown) > 0 {
+		num, fieldType, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return result
+		}
+
+		m := protowire.ConsumeFieldValue(num, fieldType, unknown[n:])
+		if m < 0 {
+			return result
+		}
+		if num != field {
+			result = append(result, unknown[:n+m]...)
+		}
+		unknown = unknown[n+m:]
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	u "server/utils"
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills bucket with tokens accumulated since the last call and takes one if available
+func (b *tokenBucket) take(limit u.RateLimit, now time.Time) bool {
+	b.tokens = min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type rateLimiter struct {
+	lock    sync.Mutex
+	buckets map[uint32]map[string]*tokenBucket
+	// times of violations inside the violation window
+	violations map[uint32][]time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets:    make(map[uint32]map[string]*tokenBucket),
+		violations: make(map[uint32][]time.Time),
+	}
+}
+
+// allow reports whether player can send another message of given variant,
+// players exceeding their limits too many times within the violation window get kicked
+func (r *rateLimiter) allow(playerID uint32, variant string, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	limit, ok := config.RateLimits[variant]
+	if !ok {
+		limit = config.DefaultRateLimit
+	}
+
+	playerBuckets, ok := r.buckets[playerID]
+	if !ok {
+		playerBuckets = make(map[string]*tokenBucket)
+		r.buckets[playerID] = playerBuckets
+	}
+
+	bucket, ok := playerBuckets[variant]
+	if !ok {
+		bucket = &tokenBucket{tokens: limit.Burst, last: now}
+		playerBuckets[variant] = bucket
+	}
+
+	if bucket.take(limit, now) {
+		return true
+	}
+
+	violations := r.recordViolation(playerID, now)
+	logger.Warn("Player exceeded message rate limit", "playerId", playerID, "variant", variant, "violations", violations)
+	if violations > config.MaxRateLimitViolations {
+		kicks.request(playerID, "Too many messages")
+	}
+	return false
+}
+
+// recordViolation forgets violations older than the window and returns number of the remaining ones
+func (r *rateLimiter) recordViolation(playerID uint32, now time.Time) int {
+	window := time.Duration(config.RateLimitViolationWindow * float64(time.Second))
+	violations := r.violations[playerID]
+	expired := 0
+	for expired < len(violations) && now.Sub(violations[expired]) > window {
+		expired++
+	}
+	violations = append(violations[expired:], now)
+	r.violations[playerID] = violations
+	return len(violations)
+}
+
+// allowSize reports whether message of given size can be accepted from player,
+// too big messages are never sent by a well-behaving client, so sender gets kicked right away
+func (r *rateLimiter) allowSize(playerID uint32, size int) bool {
+	if size >= 0 && size <= maxMessageSize() {
+		return true
+	}
+
+	kicks.request(playerID, "Message too big")
+	logger.Warn("Player sent message exceeding size limit", "playerId", playerID, "size", size)
+	return false
+}
+
+func (r *rateLimiter) removePlayer(playerID uint32) {
+	r.lock.Lock()
+	delete(r.buckets, playerID)
+	delete(r.violations, playerID)
+	r.lock.Unlock()
+}
+
+// maxMessageSize returns the biggest message that fits into the read buffer together with its prefix
+func maxMessageSize() int {
+	if config.MaxMessageSize <= 0 {
+		return BUF_SIZE - PREFIX_SIZE
+	}
+	return min(config.MaxMessageSize, BUF_SIZE-PREFIX_SIZE)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	u "server/utils"
+)
+
+// withRateLimits replaces rate limits in config for the test
+func withRateLimits(t *testing.T, limit u.RateLimit, maxViolations int, window float64) {
+	t.Helper()
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.DefaultRateLimit = limit
+	config.RateLimits = u.RateLimits{}
+	config.MaxRateLimitViolations = maxViolations
+	config.RateLimitViolationWindow = window
+}
+
+func TestTokenBucket(t *testing.T) {
+	limit := u.RateLimit{Rate: 2, Burst: 3}
+	start := time.Now()
+	bucket := &tokenBucket{tokens: limit.Burst, last: start}
+
+	steps := []struct {
+		after   time.Duration
+		allowed bool
+	}{
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false},
+		{250 * time.Millisecond, false},
+		{500 * time.Millisecond, true},
+		{500 * time.Millisecond, false},
+		// bucket doesn't fill over its burst
+		{time.Minute, true},
+		{time.Minute, true},
+		{time.Minute, true},
+		{time.Minute, false},
+	}
+
+	for i, step := range steps {
+		if allowed := bucket.take(limit, start.Add(step.after)); allowed != step.allowed {
+			t.Errorf("step %d after %v: allowed %v, expected %v", i, step.after, allowed, step.allowed)
+		}
+	}
+}
+
+func TestRateLimiterKicksOnlyForViolationsInsideWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		kicked   bool
+	}{
+		{"flood", 10 * time.Millisecond, true},
+		{"violations spread over time", 2 * time.Second, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// one message per second without burst, three violations within five seconds are tolerated
+			withRateLimits(t, u.RateLimit{Rate: 1, Burst: 1}, 3, 5)
+			kicks = newKickQueue()
+			kicks.connect(1)
+			limiter := newRateLimiter()
+
+			now := time.Now()
+			limiter.allow(1, "CHAT_MESSAGE", now)
+			for i := 0; i < 10; i++ {
+				// the second message of every pair exceeds the limit
+				now = now.Add(test.interval)
+				limiter.allow(1, "CHAT_MESSAGE", now)
+				limiter.allow(1, "CHAT_MESSAGE", now)
+			}
+
+			if _, kicked := kicks.take(1); kicked != test.kicked {
+				t.Errorf("player kicked: %v, expected %v", kicked, test.kicked)
+			}
+		})
+	}
+}
+
+func TestRateLimiterKeepsVariantsApart(t *testing.T) {
+	withRateLimits(t, u.RateLimit{Rate: 1, Burst: 1}, 100, 5)
+	kicks = newKickQueue()
+	limiter := newRateLimiter()
+	now := time.Now()
+
+	if !limiter.allow(1, "CHAT_MESSAGE", now) || limiter.allow(1, "CHAT_MESSAGE", now) {
+		t.Errorf("the second chat message should be rejected")
+	}
+	if !limiter.allow(1, "ROOM_CHANGED", now) {
+		t.Errorf("room change was limited by chat messages")
+	}
+	if !limiter.allow(2, "CHAT_MESSAGE", now) {
+		t.Errorf("player was limited by messages of another player")
+	}
+
+	limiter.removePlayer(1)
+	if !limiter.allow(1, "CHAT_MESSAGE", now) {
+		t.Errorf("reconnected player kept limits of the previous connection")
+	}
+}
+
+func TestAllowSize(t *testing.T) {
+	tests := []struct {
+		size    int
+		allowed bool
+	}{
+		{-1, false},
+		{0, true},
+		{maxMessageSize(), true},
+		{maxMessageSize() + 1, false},
+	}
+
+	for _, test := range tests {
+		kicks = newKickQueue()
+		kicks.connect(1)
+		allowed := newRateLimiter().allowSize(1, test.size)
+		_, kicked := kicks.take(1)
+		if allowed != test.allowed || kicked == test.allowed {
+			t.Errorf("message of size %d allowed: %v, kicked: %v", test.size, allowed, kicked)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"google.golang.org/protobuf/proto"
+	g "server/game-controllers"
+)
+
+type floorItem struct {
+	item Item
+	x, y float32
+}
+
+// roomState is everything that should look the same when players come back to the room
+type roomState struct {
+	enemies     map[uint32]*g.Enemy
+	spawned     bool
+	cleared     bool
+	chestOpened bool
+	items       map[uint32]floorItem
+}
+
+func newRoomState() *roomState {
+	return &roomState{
+		enemies: make(map[uint32]*g.Enemy),
+		items:   make(map[uint32]floorItem),
+	}
+}
+
+// handleEnemyHit applies damage dealt by player to the enemy, killed enemies are removed from the room
+// and room is cleared when the last of them dies. connLock has to be read locked by the caller.
+func handleEnemyHit(update *pb.StateUpdate, id uint32) {
+	hit := update.GetEnemyGotHitUpdate()
+	if hit == nil {
+		return
+	}
+
+	damage := attackDamage(id, update.GetPlayer().GetPlayerAttackDamage())
+
+	gameLock.Lock()
+	threat := damage * game.threatModifier(id)
+	gameLock.Unlock()
+
+	enemiesLock.Lock()
+	enemy, ok := enemies[hit.EnemyId]
+	if !ok {
+		enemiesLock.Unlock()
+		return
+	}
+
+	hpBefore := enemy.GetHp()
+	killed := enemy.TakeDamage(damage)
+	if killed {
+		delete(enemies, hit.EnemyId)
+		enemyIds.returnID(hit.EnemyId)
+	} else {
+		enemy.AddThreat(id, threat)
+		knockBack(enemy, id, update.GetPlayer())
+	}
+	bossUpdate := handleBossHit(enemy, killed)
+	cleared := killed && len(enemies) == 0 && isSpawned.Load()
+	enemiesLock.Unlock()
+
+	if bossUpdate != nil {
+		broadcastUpdate(bossUpdate)
+	}
+
+	gameLock.Lock()
+	statsUpdate := game.recordHit(id, enemy.GetName(), hpBefore-enemy.GetHp(), killed)
+	gameLock.Unlock()
+	if statsUpdate != nil {
+		broadcastUpdate(statsUpdate)
+	}
+
+	if killed {
+		logger.Info("Enemy killed", "enemyId", hit.EnemyId, "playerId", id)
+		dropLoot(enemy)
+	}
+	if cleared {
+		gameLock.Lock()
+		updates := game.clearCurrentRoom()
+		gameLock.Unlock()
+
+		broadcastUpdate(&pb.StateUpdate{Variant: pb.StateVariant_ROOM_CLEARED})
+		for _, update := range updates {
+			broadcastUpdate(update)
+		}
+	}
+}
+
+// attackDamage returns damage reported by player limited to what player can deal with potions,
+// clients that don't report damage deal the default one
+func attackDamage(id uint32, reported float64) float64 {
+	if reported <= 0 {
+		return config.PlayerAttackDamage
+	}
+
+	maxDamage := config.PlayerAttackDamage * max(1, config.AttackDamageTolerance)
+	if reported > maxDamage {
+		logger.Warn("Suspicious player damage", "playerId", id, "damage", reported, "maxDamage", maxDamage)
+		return maxDamage
+	}
+	return reported
+}
+
+// handleFloorItemUpdate keeps track of items lying in the current room and of rooms without enemies,
+// connLock has to be read locked by the caller
+func handleFloorItemUpdate(update *pb.StateUpdate, id uint32) {
+	gameLock.Lock()
+	updates := make([]*pb.StateUpdate, 0)
+	state := game.currentRoomState()
+	switch update.Variant {
+	case pb.StateVariant_CHEST_OPENED:
+		state.chestOpened = true
+		if update.Item != nil {
+			state.dropItem(update.Item, update.GetPlayer())
+		}
+	case ITEM_DROPPED:
+		if update.Item != nil {
+			state.dropItem(update.Item, update.GetPlayer())
+		}
+	case pb.StateVariant_ITEM_EQUIPPED:
+		if update.Item == nil {
+			break
+		}
+		if _, onFloor := state.items[update.Item.Id]; onFloor {
+			delete(state.items, update.Item.Id)
+			if statsUpdate := game.recordItemCollected(id); statsUpdate != nil {
+				updates = append(updates, statsUpdate)
+			}
+		}
+	case pb.StateVariant_ROOM_CLEARED:
+		// players are rewarded when the server sees the last enemy die, clients only
+		// report rooms which have no enemies left, e.g. rooms without any spawners
+		enemiesLock.Lock()
+		if isSpawned.Load() && len(enemies) == 0 {
+			state.cleared = true
+		}
+		enemiesLock.Unlock()
+	}
+	gameLock.Unlock()
+
+	for _, statsUpdate := range updates {
+		broadcastUpdate(statsUpdate)
+	}
+}
+
+func (r *roomState) dropItem(item *pb.Item, player *pb.Player) {
+	r.items[item.Id] = floorItem{
+		item: Item{id: item.Id, r: item.Gen, variant: item.Type},
+		x:    player.GetPositionX(),
+		y:    player.GetPositionY(),
+	}
+}
+
+// toProtoRoomState creates update describing room the players have just entered
+func (r *roomState) toProtoRoomState(room *g.Room) *pb.StateUpdate {
+	msg := &pb.StateUpdate{
+		Variant: ROOM_STATE,
+		Room:    toProtoRoom(room),
+	}
+
+	for _, floorItem := range r.items {
+		protoItem := floorItem.item.intoProtoItem()
+		setExtFloat(protoItem, EXT_ITEM_POSITION_X, floorItem.x)
+		setExtFloat(protoItem, EXT_ITEM_POSITION_Y, floorItem.y)
+		addExtMessage(msg, EXT_FLOOR_ITEMS, protoItem)
+	}
+	setExtBool(msg, EXT_ROOM_CLEARED, r.cleared)
+	setExtBool(msg, EXT_CHEST_OPENED, r.chestOpened)
+
+	return msg
+}
+
+// broadcastUpdate sends update to every player, connLock has to be read locked by the caller
+func broadcastUpdate(update *pb.StateUpdate) {
+	serializedMsg, err := proto.Marshal(update)
+	if err != nil {
+		logger.Info("Failed to serialize state update", "variant", stateVariantName(update.Variant), "error", err)
+		return
+	}
+
+	encoded := addPrefixAndPadding(serializedMsg)
+	for _, conn := range tcpConns {
+		conn.Write(encoded)
+	}
+}
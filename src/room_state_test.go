@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestAttackDamageIsLimited(t *testing.T) {
+	maxDamage := config.PlayerAttackDamage * config.AttackDamageTolerance
+	tests := []struct {
+		name     string
+		reported float64
+		expected float64
+	}{
+		{"not reported", 0, config.PlayerAttackDamage},
+		{"negative", -5, config.PlayerAttackDamage},
+		{"default", config.PlayerAttackDamage, config.PlayerAttackDamage},
+		{"boosted", maxDamage, maxDamage},
+		{"over the limit", maxDamage + 1, maxDamage},
+		{"one shot", 1e9, maxDamage},
+	}
+
+	for _, test := range tests {
+		if damage := attackDamage(1, test.reported); damage != test.expected {
+			t.Errorf("%s: reported damage %v was taken as %v, expected %v", test.name, test.reported, damage, test.expected)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	"google.golang.org/protobuf/proto"
+	g "server/game-controllers"
+)
+
+const (
+	TRANSITION_ANY      = "any"
+	TRANSITION_ALL      = "all"
+	TRANSITION_MAJORITY = "majority"
+	TRANSITION_LEADER   = "leader"
+)
+
+// roomTransitionVote gathers players standing at the exit to the same room,
+// there is only one vote at a time and voting for another room starts it from scratch
+type roomTransitionVote struct {
+	lock     sync.Mutex
+	target   *g.Room
+	voters   map[uint32]bool
+	deadline time.Time
+}
+
+func newRoomTransitionVote() *roomTransitionVote {
+	return &roomTransitionVote{
+		voters: make(map[uint32]bool),
+	}
+}
+
+func (v *roomTransitionVote) vote(playerID uint32, target *g.Room, now time.Time) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.target != target {
+		v.target = target
+		v.voters = make(map[uint32]bool)
+		v.deadline = now.Add(time.Duration(config.RoomTransitionVoteTimeout * float64(time.Second)))
+	}
+	v.voters[playerID] = true
+}
+
+func (v *roomTransitionVote) removePlayer(playerID uint32) {
+	v.lock.Lock()
+	delete(v.voters, playerID)
+	v.lock.Unlock()
+}
+
+func (v *roomTransitionVote) reset() {
+	v.lock.Lock()
+	v.target = nil
+	v.voters = make(map[uint32]bool)
+	v.lock.Unlock()
+}
+
+// expire cancels vote which wasn't decided in time, returns whether there was such vote
+func (v *roomTransitionVote) expire(now time.Time) bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.target == nil || config.RoomTransitionVoteTimeout <= 0 || now.Before(v.deadline) {
+		return false
+	}
+
+	v.target = nil
+	v.voters = make(map[uint32]bool)
+	return true
+}
+
+// resolve reports whether enough players voted for the target room according to the transition policy,
+// otherwise it returns how many more votes are needed. connLock has to be read locked by the caller.
+func (v *roomTransitionVote) resolve() (*g.Room, int, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.target == nil {
+		return nil, 0, false
+	}
+
+	votes := 0
+	leaderVoted := false
+	leader := uint32(PLAYER_MAX_ID + 1)
+	for id := range tcpConns {
+		leader = min(leader, id)
+	}
+	for id := range v.voters {
+		if _, connected := tcpConns[id]; connected {
+			votes++
+			leaderVoted = leaderVoted || id == leader
+		}
+	}
+
+	var ready bool
+	needed := 0
+	switch config.RoomTransitionPolicy {
+	case TRANSITION_ALL:
+		needed = len(tcpConns) - votes
+		ready = needed <= 0
+	case TRANSITION_MAJORITY:
+		needed = len(tcpConns)/2 + 1 - votes
+		ready = needed <= 0
+	case TRANSITION_LEADER:
+		needed = 1
+		ready = leaderVoted
+	default:
+		ready = true
+	}
+
+	if !ready {
+		return v.target, needed, false
+	}
+
+	target := v.target
+	v.target = nil
+	v.voters = make(map[uint32]bool)
+	return target, 0, true
+}
+
+// resolveTransitionVote moves everybody to the target room once the vote is decided, otherwise players
+// are told how many votes are still missing. It's called after every vote and after players leave, so party
+// doesn't wait for somebody who isn't there anymore. connLock has to be read locked by the caller.
+func resolveTransitionVote(graphCh chan bool) {
+	target, needed, ready := transitionVote.resolve()
+	switch {
+	case ready:
+		signalGraph(graphCh, false)
+		handleRoomChange(target)
+	case target != nil:
+		broadcastTransitionVote(target, needed)
+	}
+}
+
+// broadcastTransitionVote informs players about room everybody waits to go to and missing votes,
+// connLock has to be read locked by the caller
+func broadcastTransitionVote(target *g.Room, needed int) {
+	responseMsg := &pb.StateUpdate{
+		Variant: ROOM_TRANSITION_VOTE,
+		Room:    toProtoRoom(target),
+	}
+	setExtUint(responseMsg, EXT_VOTES_NEEDED, uint64(needed))
+
+	serializedMsg, err := proto.Marshal(responseMsg)
+	if err != nil {
+		logger.Info("Failed to serialize room transition vote", "error", err)
+		return
+	}
+
+	encoded := addPrefixAndPadding(serializedMsg)
+	var waitingMsg []byte
+	if config.RoomTransitionPolicy == TRANSITION_LEADER {
+		waitingMsg = systemMessage("Waiting for the leader")
+	} else {
+		waitingMsg = systemMessage(fmt.Sprintf("Waiting for %d players", needed))
+	}
+
+	for _, conn := range tcpConns {
+		conn.Write(encoded)
+		conn.Write(waitingMsg)
+	}
+}
+
+// broadcastTransitionCancelled informs players that the vote timed out,
+// connLock has to be read locked by the caller
+func broadcastTransitionCancelled() {
+	responseMsg := &pb.StateUpdate{
+		Variant: ROOM_TRANSITION_VOTE,
+	}
+	setExtUint(responseMsg, EXT_VOTES_NEEDED, 0)
+
+	serializedMsg, err := proto.Marshal(responseMsg)
+	if err != nil {
+		logger.Info("Failed to serialize room transition vote", "error", err)
+		return
+	}
+
+	encoded := addPrefixAndPadding(serializedMsg)
+	cancelledMsg := systemMessage("Not everybody came to the exit in time")
+	for _, conn := range tcpConns {
+		conn.Write(encoded)
+		conn.Write(cancelledMsg)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	g "server/game-controllers"
+)
+
+func TestRoomTransitionVoteResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		connected []uint32
+		voters    []uint32
+		ready     bool
+		needed    int
+	}{
+		{"any", TRANSITION_ANY, []uint32{1, 2, 3}, []uint32{3}, true, 0},
+		{"all voted", TRANSITION_ALL, []uint32{1, 2, 3}, []uint32{1, 2, 3}, true, 0},
+		{"all but one", TRANSITION_ALL, []uint32{1, 2, 3}, []uint32{1, 3}, false, 1},
+		{"majority reached", TRANSITION_MAJORITY, []uint32{1, 2, 3}, []uint32{2, 3}, true, 0},
+		{"half isn't majority", TRANSITION_MAJORITY, []uint32{1, 2, 3, 4}, []uint32{1, 2}, false, 1},
+		{"leader voted", TRANSITION_LEADER, []uint32{2, 5}, []uint32{2}, true, 0},
+		{"leader didn't vote", TRANSITION_LEADER, []uint32{2, 5}, []uint32{5}, false, 1},
+		// votes of players that left don't count
+		{"voter left", TRANSITION_ALL, []uint32{1, 2}, []uint32{1, 7}, false, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saved := config
+			t.Cleanup(func() { config = saved })
+			config.RoomTransitionPolicy = test.policy
+
+			tcpConns = make(map[uint32]*net.TCPConn)
+			for _, id := range test.connected {
+				tcpConns[id] = nil
+			}
+
+			room := &g.Room{Id: 4}
+			vote := newRoomTransitionVote()
+			for _, id := range test.voters {
+				vote.vote(id, room, time.Now())
+			}
+
+			target, needed, ready := vote.resolve()
+			if target != room || ready != test.ready || needed != test.needed {
+				t.Errorf("resolved room %v, ready: %v, needed: %d; expected room 4, ready: %v, needed: %d",
+					target, ready, needed, test.ready, test.needed)
+			}
+			if ready {
+				if target, _, _ := vote.resolve(); target != nil {
+					t.Errorf("decided vote wasn't cleared")
+				}
+			}
+		})
+	}
+}
+
+func TestRoomTransitionVoteForAnotherRoomStartsOver(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.RoomTransitionPolicy = TRANSITION_ALL
+	tcpConns = map[uint32]*net.TCPConn{1: nil, 2: nil}
+
+	first, second := &g.Room{Id: 4}, &g.Room{Id: 5}
+	vote := newRoomTransitionVote()
+	vote.vote(1, first, time.Now())
+	vote.vote(2, second, time.Now())
+
+	if target, needed, ready := vote.resolve(); target != second || ready || needed != 1 {
+		t.Errorf("resolved room %v, ready: %v, needed: %d; expected only the vote for room 5", target, ready, needed)
+	}
+
+	vote.removePlayer(2)
+	delete(tcpConns, 2)
+	if target, _, ready := vote.resolve(); target != second || ready {
+		t.Errorf("vote without voters resolved room %v, ready: %v", target, ready)
+	}
+}
+
+func TestRoomTransitionVoteExpires(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.RoomTransitionPolicy = TRANSITION_ALL
+	config.RoomTransitionVoteTimeout = 10
+	tcpConns = map[uint32]*net.TCPConn{1: nil, 2: nil}
+
+	start := time.Now()
+	vote := newRoomTransitionVote()
+	vote.vote(1, &g.Room{Id: 4}, start)
+
+	if vote.expire(start.Add(9 * time.Second)) {
+		t.Errorf("vote expired before its timeout")
+	}
+	if !vote.expire(start.Add(10 * time.Second)) {
+		t.Errorf("vote didn't expire after its timeout")
+	}
+	if target, _, _ := vote.resolve(); target != nil {
+		t.Errorf("expired vote still waits for room %v", target)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	pb "github.com/kmrd-industries/qlp-proto-bindings/gen/go"
+	g "server/game-controllers"
+	u "server/utils"
+)
+
+// Snapshot of the run written as JSON. Dungeon layout isn't stored, it's generated again from the seed,
+// so resuming with different dungeon settings in config gives a different dungeon.
+type gameSnapshot struct {
+	Seed        int64                     `json:"seed"`
+	CurrentRoom int                       `json:"currentRoom"`
+	Inventories map[string][]itemSnapshot `json:"inventories"`
+	Players     map[string]playerSnapshot `json:"players"`
+	Generator   generatorSnapshot         `json:"generator"`
+	EnemyIDs    idPoolSnapshot            `json:"enemyIds"`
+	Rooms       []roomSnapshot            `json:"rooms"`
+}
+
+// playerSnapshot is health and progress of player, players get it back when they join with the same name
+type playerSnapshot struct {
+	HP    float64     `json:"hp"`
+	Life  string      `json:"life"`
+	Stats playerStats `json:"stats"`
+}
+
+type itemSnapshot struct {
+	ID   uint32      `json:"id"`
+	Gen  uint32      `json:"gen"`
+	Type pb.ItemType `json:"type"`
+}
+
+type idPoolSnapshot struct {
+	NextID       uint32   `json:"nextId"`
+	AvailableIDs []uint32 `json:"availableIds"`
+}
+
+type generatorSnapshot struct {
+	CurrentGeneration  uint32                 `json:"currentGeneration"`
+	RandintGenerations map[uint32]uint32      `json:"randintGenerations"`
+	IDGenerations      map[uint32]uint32      `json:"idGenerations"`
+	VariantGenerations map[uint32]pb.ItemType `json:"variantGenerations"`
+	NextRandint        []uint32               `json:"nextRandint"`
+	NextID             []uint32               `json:"nextId"`
+	NextVariant        []pb.ItemType          `json:"nextVariant"`
+	NextGeneration     []uint32               `json:"nextGeneration"`
+	ItemIDs            idPoolSnapshot         `json:"itemIds"`
+}
+
+type enemySnapshot struct {
+	ID   uint32  `json:"id"`
+	Name string  `json:"name"`
+	X    int     `json:"x"`
+	Y    int     `json:"y"`
+	HP   float64 `json:"hp"`
+}
+
+type floorItemSnapshot struct {
+	Item itemSnapshot `json:"item"`
+	X    float32      `json:"x"`
+	Y    float32      `json:"y"`
+}
+
+type roomSnapshot struct {
+	ID          int                 `json:"id"`
+	Spawned     bool                `json:"spawned"`
+	Cleared     bool                `json:"cleared"`
+	ChestOpened bool                `json:"chestOpened"`
+	Enemies     []enemySnapshot     `json:"enemies"`
+	Items       []floorItemSnapshot `json:"items"`
+}
+
+// saveRun writes current run to file
+func saveRun(path string) error {
+	gameLock.Lock()
+	enemiesLock.Lock()
+	// snapshot shares maps with the running game, so it has to be serialized before they change
+	data, err := json.MarshalIndent(game.snapshot(), "", "  ")
+	enemiesLock.Unlock()
+	gameLock.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// resumeRun replaces current run with the one saved in file, it should be called before players connect
+func resumeRun(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	snapshot := gameSnapshot{}
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	restored, err := gameFromSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	gameLock.Lock()
+	game = restored
+	enemiesLock.Lock()
+	state := game.currentRoomState()
+	enemies = state.enemies
+	isSpawned.Store(state.spawned)
+	enemiesLock.Unlock()
+	gameLock.Unlock()
+	return nil
+}
+
+// snapshot captures the whole run, gameLock and enemiesLock have to be locked by the caller
+func (g *Game) snapshot() gameSnapshot {
+	// enemies of the current room are kept aside until players leave it
+	current := g.currentRoomState()
+	current.enemies = enemies
+	current.spawned = isSpawned.Load()
+
+	inventories := make(map[string][]itemSnapshot, len(g.savedInventories))
+	for name, items := range g.savedInventories {
+		inventories[name] = snapshotItems(items)
+	}
+	now := time.Now()
+	players := make(map[string]playerSnapshot, len(g.savedProgress))
+	for name, progress := range g.savedProgress {
+		players[name] = progress
+	}
+	for _, player := range g.players {
+		if player.registered {
+			inventories[player.name] = snapshotItems(player.items)
+			players[player.name] = player.snapshot(now)
+		}
+	}
+
+	rooms := make([]roomSnapshot, 0, len(g.rooms))
+	for id, state := range g.rooms {
+		rooms = append(rooms, state.snapshot(id))
+	}
+
+	return gameSnapshot{
+		Seed:        g.seed,
+		CurrentRoom: g.currentRoom.Id,
+		Inventories: inventories,
+		Players:     players,
+		Generator:   g.generator.snapshot(),
+		EnemyIDs:    enemyIds.snapshot(),
+		Rooms:       rooms,
+	}
+}
+
+func gameFromSnapshot(snapshot gameSnapshot) (*Game, error) {
+	restored := newGame()
+	restored.seed = snapshot.Seed
+	restored.dungeon = g.GenerateDungeon(snapshot.Seed, dungeonSettings())
+
+	currentRoom, ok := restored.dungeon.GetRoom(snapshot.CurrentRoom)
+	if !ok {
+		return nil, fmt.Errorf("room %d doesn't exist in dungeon generated from seed %d", snapshot.CurrentRoom, snapshot.Seed)
+	}
+	restored.currentRoom = currentRoom
+
+	for name, items := range snapshot.Inventories {
+		restored.savedInventories[name] = restoreItems(items)
+	}
+	for name, progress := range snapshot.Players {
+		restored.savedProgress[name] = progress
+	}
+
+	generator, err := generatorFromSnapshot(snapshot.Generator)
+	if err != nil {
+		return nil, err
+	}
+	restored.generator = generator
+
+	for _, room := range snapshot.Rooms {
+		state, err := roomStateFromSnapshot(room)
+		if err != nil {
+			return nil, err
+		}
+		restored.rooms[room.ID] = state
+	}
+
+	enemyIds.restore(snapshot.EnemyIDs)
+	return restored, nil
+}
+
+func (p *Player) snapshot(now time.Time) playerSnapshot {
+	return playerSnapshot{
+		HP:    p.hp,
+		Life:  lifeStateNames[p.life],
+		Stats: p.stats.current(now),
+	}
+}
+
+// restore gives player health and progress from saved run, downed players get full time to be revived again
+func (s playerSnapshot) restore(player *Player, now time.Time) {
+	player.hp = s.HP
+	player.life = PLAYER_ALIVE
+	for life, name := range lifeStateNames {
+		if name == s.Life {
+			player.life = life
+		}
+	}
+
+	player.stats = s.Stats
+	if player.stats.Kills == nil {
+		player.stats.Kills = make(map[string]int)
+	}
+	player.stats.Level = max(1, player.stats.Level)
+	player.stats.aliveSince = time.Time{}
+	switch player.life {
+	case PLAYER_ALIVE:
+		player.stats.startAlive(now)
+	case PLAYER_DOWNED:
+		player.downedUntil = now.Add(time.Duration(config.DownedTime * float64(time.Second)))
+		player.reviveProgress = 0
+	}
+}
+
+func (ig *ItemGenerator) snapshot() generatorSnapshot {
+	return generatorSnapshot{
+		CurrentGeneration:  ig.currentGeneration,
+		RandintGenerations: ig.randintGenerations,
+		IDGenerations:      ig.idGenerations,
+		VariantGenerations: ig.variantGenerations,
+		NextRandint:        ig.nextRandint,
+		NextID:             ig.nextID,
+		NextVariant:        ig.nextVariant,
+		NextGeneration:     ig.nextGeneration,
+		ItemIDs:            ig.itemIDs.snapshot(),
+	}
+}
+
+func generatorFromSnapshot(snapshot generatorSnapshot) (*ItemGenerator, error) {
+	players := MAX_PLAYERS + 1
+	if len(snapshot.NextRandint) != players || len(snapshot.NextID) != players ||
+		len(snapshot.NextVariant) != players || len(snapshot.NextGeneration) != players {
+		return nil, fmt.Errorf("item generator was saved for different number of players than %d", players)
+	}
+
+	if snapshot.RandintGenerations == nil || snapshot.IDGenerations == nil || snapshot.VariantGenerations == nil {
+		return nil, fmt.Errorf("item generator generations are missing")
+	}
+
+	generator := newGenerator(players)
+	generator.currentGeneration = snapshot.CurrentGeneration
+	generator.randintGenerations = snapshot.RandintGenerations
+	generator.idGenerations = snapshot.IDGenerations
+	generator.variantGenerations = snapshot.VariantGenerations
+	generator.nextRandint = snapshot.NextRandint
+	generator.nextID = snapshot.NextID
+	generator.nextVariant = snapshot.NextVariant
+	generator.nextGeneration = snapshot.NextGeneration
+	generator.itemIDs.restore(snapshot.ItemIDs)
+
+	return generator, nil
+}
+
+func (p *idPool) snapshot() idPoolSnapshot {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return idPoolSnapshot{
+		NextID:       p.nextID,
+		AvailableIDs: append([]uint32{}, *p.availableIDs...),
+	}
+}
+
+func (p *idPool) restore(snapshot idPoolSnapshot) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	availableIDs := PriorityQueue(append([]uint32{}, snapshot.AvailableIDs...))
+	heap.Init(&availableIDs)
+	p.availableIDs = &availableIDs
+	p.nextID = snapshot.NextID
+}
+
+func (r *roomState) snapshot(id int) roomSnapshot {
+	snapshot := roomSnapshot{
+		ID:          id,
+		Spawned:     r.spawned,
+		Cleared:     r.cleared,
+		ChestOpened: r.chestOpened,
+		Enemies:     make([]enemySnapshot, 0, len(r.enemies)),
+		Items:       make([]floorItemSnapshot, 0, len(r.items)),
+	}
+
+	for _, enemy := range r.enemies {
+		snapshot.Enemies = append(snapshot.Enemies, enemySnapshot{
+			ID:   enemy.GetId(),
+			Name: enemy.GetName(),
+			X:    enemy.GetPosition().X,
+			Y:    enemy.GetPosition().Y,
+			HP:   enemy.GetHp(),
+		})
+	}
+	for _, item := range r.items {
+		snapshot.Items = append(snapshot.Items, floorItemSnapshot{
+			Item: snapshotItem(item.item),
+			X:    item.x,
+			Y:    item.y,
+		})
+	}
+
+	return snapshot
+}
+
+func roomStateFromSnapshot(snapshot roomSnapshot) (*roomState, error) {
+	state := newRoomState()
+	state.spawned = snapshot.Spawned
+	state.cleared = snapshot.Cleared
+	state.chestOpened = snapshot.ChestOpened
+
+	for _, enemy := range snapshot.Enemies {
+		enemyConfig, ok := enemyConfigByName(enemy.Name)
+		if !ok {
+			return nil, fmt.Errorf("enemy %s isn't defined in config", enemy.Name)
+		}
+
+		// restored enemies behave like freshly spawned ones, bosses get their controllers on the next update
+		restored := newEnemyFromConfig(enemy.ID, enemy.X, enemy.Y, enemyConfig)
+		restored.SetHp(enemy.HP)
+		state.enemies[enemy.ID] = restored
+	}
+	for _, item := range snapshot.Items {
+		state.items[item.Item.ID] = floorItem{
+			item: restoreItem(item.Item),
+			x:    item.X,
+			y:    item.Y,
+		}
+	}
+
+	return state, nil
+}
+
+func enemyConfigByName(name string) (u.EnemyData, bool) {
+	for _, enemyConfig := range config.EnemyData {
+		if enemyConfig.Name == name {
+			return enemyConfig, true
+		}
+	}
+	return u.EnemyData{}, false
+}
+
+func snapshotItem(item Item) itemSnapshot {
+	return itemSnapshot{ID: item.id, Gen: item.r, Type: item.variant}
+}
+
+func restoreItem(item itemSnapshot) Item {
+	return Item{id: item.ID, r: item.Gen, variant: item.Type}
+}
+
+func snapshotItems(items []Item) []itemSnapshot {
+	snapshots := make([]itemSnapshot, len(items))
+	for i, item := range items {
+		snapshots[i] = snapshotItem(item)
+	}
+	return snapshots
+}
+
+func restoreItems(snapshots []itemSnapshot) []Item {
+	items := make([]Item, len(snapshots))
+	for i, snapshot := range snapshots {
+		items[i] = restoreItem(snapshot)
+	}
+	return items
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	g "server/game-controllers"
+)
+
+// otherRoom returns any room of the dungeon players don't start in
+func otherRoom(t *testing.T, game *Game) int {
+	t.Helper()
+
+	for _, room := range game.dungeon.GetRooms() {
+		if room.Id != game.currentRoom.Id {
+			return room.Id
+		}
+	}
+	t.Fatalf("dungeon has only the starting room")
+	return 0
+}
+
+func TestSnapshotRoundTripKeepsRooms(t *testing.T) {
+	game = newGame()
+	enemyIds = newIDPool(ENEMY_MIN_ID, ENEMY_MAX_ID)
+	first, second, third := enemyIds.getID(), enemyIds.getID(), enemyIds.getID()
+	enemyIds.returnID(second)
+
+	// survivors of the fight in the current room
+	enemies = map[uint32]*g.Enemy{
+		first: newEnemyFromConfig(first, 4, 5, config.EnemyData[0]),
+		third: newEnemyFromConfig(third, 7, 2, config.EnemyData[1]),
+	}
+	enemies[first].SetHp(3)
+	isSpawned.Store(true)
+
+	cleared := otherRoom(t, game)
+	game.rooms[cleared] = &roomState{
+		enemies:     make(map[uint32]*g.Enemy),
+		spawned:     true,
+		cleared:     true,
+		chestOpened: true,
+		items:       make(map[uint32]floorItem),
+	}
+
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := saveRun(path); err != nil {
+		t.Fatalf("couldn't save run: %v", err)
+	}
+	savedSeed, savedRoom := game.seed, game.currentRoom.Id
+
+	game = newGame()
+	enemies = make(map[uint32]*g.Enemy)
+	isSpawned.Store(false)
+	enemyIds = newIDPool(ENEMY_MIN_ID, ENEMY_MAX_ID)
+	if err := resumeRun(path); err != nil {
+		t.Fatalf("couldn't resume run: %v", err)
+	}
+
+	if game.seed != savedSeed || game.currentRoom.Id != savedRoom {
+		t.Errorf("resumed seed %d in room %d, saved seed %d in room %d", game.seed, game.currentRoom.Id, savedSeed, savedRoom)
+	}
+	if !isSpawned.Load() {
+		t.Errorf("current room isn't spawned after resume, players entering it would get a new set of enemies")
+	}
+
+	tests := []struct {
+		id   uint32
+		name string
+		x, y int
+		hp   float64
+	}{
+		{first, config.EnemyData[0].Name, 4, 5, 3},
+		{third, config.EnemyData[1].Name, 7, 2, config.EnemyData[1].HP},
+	}
+	if len(enemies) != len(tests) {
+		t.Fatalf("resumed %d enemies, expected %d", len(enemies), len(tests))
+	}
+	for _, test := range tests {
+		enemy, ok := enemies[test.id]
+		if !ok {
+			t.Errorf("enemy %d wasn't resumed", test.id)
+			continue
+		}
+		position := enemy.GetPosition()
+		if enemy.GetName() != test.name || position.X != test.x || position.Y != test.y || enemy.GetHp() != test.hp {
+			t.Errorf("enemy %d resumed as %s at %v with %v hp, expected %s at (%d, %d) with %v hp",
+				test.id, enemy.GetName(), position, enemy.GetHp(), test.name, test.x, test.y, test.hp)
+		}
+	}
+
+	state, ok := game.rooms[cleared]
+	if !ok || !state.spawned || !state.cleared || !state.chestOpened || len(state.enemies) != 0 {
+		t.Errorf("cleared room %d resumed as %+v", cleared, state)
+	}
+
+	// returned id is reused first, then the pool continues after the last id given before saving
+	if id := enemyIds.getID(); id != second {
+		t.Errorf("first enemy id after resume is %d, expected returned id %d", id, second)
+	}
+	if id := enemyIds.getID(); id != third+1 {
+		t.Errorf("second enemy id after resume is %d, expected %d", id, third+1)
+	}
+}
@@ -52,17 +52,122 @@ type Config struct {
 	WeaponComponentDefaultRemainingDistance float64     `json:"weaponComponentDefaultRemainingDistance"`
 	WeaponComponentDefaultRecoilAmount      float64     `json:"weaponComponentDefaultRecoilAmount"`
 	WeaponInteractionDistance               int         `json:"weaponInteractionDistance"`
+	MovementSpeedTolerance                  float64     `json:"movementSpeedTolerance"`
+	AttackDamageTolerance                   float64     `json:"attackDamageTolerance"`
+	MaxMessageSize                          int         `json:"maxMessageSize"`
+	MaxRateLimitViolations                  int         `json:"maxRateLimitViolations"`
+	RateLimitViolationWindow                float64     `json:"rateLimitViolationWindow"`
+	DefaultRateLimit                        RateLimit   `json:"defaultRateLimit"`
+	RateLimits                              RateLimits  `json:"rateLimits"`
+	MaxChatMessageLength                    int         `json:"maxChatMessageLength"`
+	ChatFilterFile                          string      `json:"chatFilterFile"`
+	ChatEmotes                              []string    `json:"chatEmotes"`
+	BanListFile                             string      `json:"banListFile"`
+	MaxPlayerNameLength                     int         `json:"maxPlayerNameLength"`
+	PlayerClasses                           []string    `json:"playerClasses"`
+	DungeonSideRoomChance                   float64     `json:"dungeonSideRoomChance"`
+	DungeonTreasureRoomChance               float64     `json:"dungeonTreasureRoomChance"`
+	RoomTransitionPolicy                    string      `json:"roomTransitionPolicy"`
+	RoomTransitionVoteTimeout               float64     `json:"roomTransitionVoteTimeout"`
+	SaveFile                                string      `json:"saveFile"`
+	AutosaveOnRoomChange                    bool        `json:"autosaveOnRoomChange"`
+	PlayerHitboxSize                        [2]float64  `json:"playerHitboxSize"`
+	DownedTime                              float64     `json:"downedTime"`
+	ReviveTime                              float64     `json:"reviveTime"`
+	ReviveDistance                          float64     `json:"reviveDistance"`
+	ReviveHPRatio                           float64     `json:"reviveHPRatio"`
+	XPCurve                                 []float64   `json:"xpCurve"`
+	RoomClearXP                             float64     `json:"roomClearXP"`
+	PathfindingMargin                       int         `json:"pathfindingMargin"`
+	ClassThreat                             ThreatTable `json:"classThreat"`
 	EnemyData                               []EnemyData `json:"enemyData"`
 	ItemsData                               []ItemData  `json:"itemsData"`
 }
 
+// RateLimit allows Burst messages at once and refills at Rate messages per second
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst float64 `json:"burst"`
+}
+
+// RateLimits maps message variant names to their limits
+type RateLimits map[string]RateLimit
+
+// ThreatTable maps player class names to multiplier of threat their hits cause
+type ThreatTable map[string]float64
+
 type EnemyData struct {
 	Type          string        `json:"type"`
 	Name          string        `json:"name"`
 	HP            float64       `json:"hp"`
 	Damage        float64       `json:"damage"`
+	XP            float64       `json:"xp"`
+	Targeting     string        `json:"targeting"`
 	TextureData   TextureData   `json:"textureData"`
 	CollisionData CollisionData `json:"collisionData"`
+	Boss          *BossData     `json:"boss,omitempty"`
+	Ranged        *RangedData   `json:"ranged,omitempty"`
+	Loot          *LootTable    `json:"loot,omitempty"`
+	Separation    *Separation   `json:"separation,omitempty"`
+	Vision        *Vision       `json:"vision,omitempty"`
+}
+
+// Vision makes enemy notice only players within Range tiles that aren't behind walls,
+// players out of sight are searched for where they were seen for Memory seconds
+type Vision struct {
+	Range  float64 `json:"range"`
+	Memory float64 `json:"memory"`
+}
+
+// Separation keeps enemies apart, enemy tries to stay Spacing tiles away from collision boxes
+// of others and Weight says how strongly compared to chasing its target
+type Separation struct {
+	Spacing float64 `json:"spacing"`
+	Weight  float64 `json:"weight"`
+}
+
+// LootTable is rolled by the server when enemy dies. Weights map item types to chance of being
+// picked relative to each other, guaranteed items drop every time besides the rolled one.
+type LootTable struct {
+	DropChance float64            `json:"dropChance"`
+	Weights    map[string]float64 `json:"weights"`
+	Guaranteed []string           `json:"guaranteed"`
+}
+
+// RangedData makes enemy stop and shoot projectiles at players in range and sight,
+// distances are in tiles and times in seconds
+type RangedData struct {
+	Projectile string  `json:"projectile"`
+	Speed      float64 `json:"speed"`
+	Lifetime   float64 `json:"lifetime"`
+	Damage     float64 `json:"damage"`
+	Cooldown   float64 `json:"cooldown"`
+	Range      float64 `json:"range"`
+	Radius     float64 `json:"radius"`
+}
+
+type BossData struct {
+	Phases  []BossPhaseData           `json:"phases"`
+	Attacks map[string]BossAttackData `json:"attacks"`
+}
+
+// BossPhaseData starts when boss health drops to HPThreshold part of its maximum health
+type BossPhaseData struct {
+	HPThreshold    float64  `json:"hpThreshold"`
+	Attacks        []string `json:"attacks"`
+	AttackCooldown float64  `json:"attackCooldown"`
+}
+
+// BossAttackData describes one of charge, slam or summon attacks, distances are in tiles and times in seconds
+type BossAttackData struct {
+	Type          string  `json:"type"`
+	TelegraphTime float64 `json:"telegraphTime"`
+	Duration      float64 `json:"duration"`
+	Speed         float64 `json:"speed"`
+	Radius        float64 `json:"radius"`
+	Damage        float64 `json:"damage"`
+	SummonName    string  `json:"summonName"`
+	SummonCount   int     `json:"summonCount"`
 }
 
 type TextureData struct {
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strings"
+)
+
+type WordListParser struct {
+}
+
+func NewWordListParser() *WordListParser {
+	return &WordListParser{}
+}
+
+// ParseWordList reads file with one word per line, empty lines and lines starting with # are skipped
+func (w *WordListParser) ParseWordList(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Println("Error opening file: ", err)
+		return nil, err
+	}
+	defer file.Close()
+
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}